@@ -0,0 +1,236 @@
+// Command readme renders a package's documentation with the godoc text
+// templates in _support, standing in for the command line mode that godoc
+// has since dropped:
+//
+//	go run _support/readme.go -templates _support . > README.md
+package main
+
+import (
+    "bytes"
+    "flag"
+    "fmt"
+    "go/ast"
+    "go/doc"
+    "go/parser"
+    "go/printer"
+    "go/token"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+    "text/template"
+)
+
+// punchCardWidth is godoc's comment width.
+const punchCardWidth = 80
+
+var (
+    templates = flag.String("templates", "_support", "directory holding package.txt")
+    tabwidth  = flag.Int("tabwidth", 4, "tab width")
+)
+
+// PageInfo is the part of godoc's PageInfo that package.txt uses.
+type PageInfo struct {
+    FSet     *token.FileSet
+    PAst     map[string]*ast.File
+    PDoc     *doc.Package
+    Examples []*doc.Example
+    Notes    map[string][]*doc.Note
+    IsMain   bool
+}
+
+func main() {
+    flag.Parse()
+
+    dir := "."
+    if flag.NArg() > 0 {
+        dir = flag.Arg(0)
+    }
+
+    info, err := pageInfo(dir)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+
+    tmpl, err := template.New("package.txt").Funcs(template.FuncMap{
+        "node":         node,
+        "comment_text": commentText,
+        "example_text": exampleText,
+        "noteTitle":    noteTitle,
+        "repeat":       strings.Repeat,
+    }).ParseFiles(filepath.Join(*templates, "package.txt"))
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+
+    if err := tmpl.Execute(os.Stdout, info); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+}
+
+/****
+ * Private methods
+ *****************************************************/
+
+func pageInfo(dir string) (*PageInfo, error) {
+    fset := token.NewFileSet()
+    pkgs, err := parser.ParseDir(fset, dir, func(fi fs.FileInfo) bool {
+        return !strings.HasSuffix(fi.Name(), "_test.go")
+    }, parser.ParseComments)
+    if err != nil {
+        return nil, err
+    }
+
+    if len(pkgs) != 1 {
+        return nil, fmt.Errorf("expected one package in %s, found %d", dir, len(pkgs))
+    }
+
+    var pkg *ast.Package
+    for _, p := range pkgs {
+        pkg = p
+    }
+
+    path, err := filepath.Abs(dir)
+    if err != nil {
+        return nil, err
+    }
+
+    info := &PageInfo{
+        FSet:   fset,
+        PDoc:   doc.New(pkg, path, 0),
+        IsMain: pkg.Name == "main",
+    }
+
+    info.Examples, err = examples(fset, dir, info.PDoc)
+    if err != nil {
+        return nil, err
+    }
+
+    // Like godoc, only BUG notes are shown.
+    if bugs := info.PDoc.Notes["BUG"]; len(bugs) > 0 {
+        info.Notes = map[string][]*doc.Note{"BUG": bugs}
+    }
+
+    return info, nil
+}
+
+// examples collects the package's examples, for exported names only.
+func examples(fset *token.FileSet, dir string, pdoc *doc.Package) ([]*doc.Example, error) {
+    globals := map[string]bool{}
+    for _, t := range pdoc.Types {
+        globals[t.Name] = true
+        for _, f := range t.Funcs {
+            globals[f.Name] = true
+        }
+        for _, m := range t.Methods {
+            globals[t.Name+"_"+m.Name] = true
+        }
+    }
+    for _, f := range pdoc.Funcs {
+        globals[f.Name] = true
+    }
+
+    names, err := filepath.Glob(filepath.Join(dir, "*_test.go"))
+    if err != nil {
+        return nil, err
+    }
+    sort.Strings(names)
+
+    var files []*ast.File
+    for _, name := range names {
+        file, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+        if err != nil {
+            return nil, err
+        }
+        files = append(files, file)
+    }
+
+    var examples []*doc.Example
+    for _, eg := range doc.Examples(files...) {
+        name := stripExampleSuffix(eg.Name)
+        if name == "" || globals[name] {
+            examples = append(examples, eg)
+        }
+    }
+
+    return examples, nil
+}
+
+// node prints x as godoc does, indenting with spaces.
+func node(info *PageInfo, x interface{}) string {
+    var buf bytes.Buffer
+    config := &printer.Config{Mode: printer.TabIndent | printer.UseSpaces, Tabwidth: *tabwidth}
+    if err := config.Fprint(&buf, info.FSet, x); err != nil {
+        return err.Error()
+    }
+
+    lines := strings.Split(buf.String(), "\n")
+    for i, line := range lines {
+        trimmed := strings.TrimLeft(line, "\t")
+        lines[i] = strings.Repeat(" ", *tabwidth*(len(line)-len(trimmed))) + trimmed
+    }
+
+    return strings.Join(lines, "\n")
+}
+
+func commentText(comment, indent, preIndent string) string {
+    var buf bytes.Buffer
+    doc.ToText(&buf, comment, indent, preIndent, punchCardWidth-2*len(indent))
+    return buf.String()
+}
+
+// exampleText prints the examples for name, each headed by indent and
+// "Example:".
+func exampleText(info *PageInfo, name, indent string) string {
+    var buf bytes.Buffer
+    first := true
+    for _, eg := range info.Examples {
+        if stripExampleSuffix(eg.Name) != name {
+            continue
+        }
+
+        if !first {
+            buf.WriteString("\n")
+        }
+        first = false
+
+        var code bytes.Buffer
+        config := &printer.Config{Mode: printer.UseSpaces, Tabwidth: *tabwidth}
+        config.Fprint(&code, info.FSet, &printer.CommentedNode{Node: eg.Code, Comments: eg.Comments})
+
+        // Unindent function bodies.
+        text := code.String()
+        if n := len(text); n >= 2 && text[0] == '{' && text[n-1] == '}' {
+            text = strings.Replace(text[1:n-1], "\n"+strings.Repeat(" ", *tabwidth), "\n", -1)
+        }
+
+        buf.WriteString(indent)
+        buf.WriteString("Example:\n")
+        for _, line := range strings.Split(strings.Trim(text, "\n"), "\n") {
+            if line != "" {
+                buf.WriteString("\t")
+            }
+            buf.WriteString(line)
+            buf.WriteString("\n")
+        }
+    }
+
+    return buf.String()
+}
+
+func noteTitle(marker string) string {
+    return strings.ToUpper(marker[:1]) + strings.ToLower(marker[1:])
+}
+
+var exampleSuffix = regexp.MustCompile(`_[a-z][A-Za-z0-9]*$`)
+
+// stripExampleSuffix strips a lowercase example suffix, e.g. "Foo_bar"
+// becomes "Foo".
+func stripExampleSuffix(name string) string {
+    return exampleSuffix.ReplaceAllString(name, "")
+}
@@ -1,21 +1,154 @@
 package main
 
 import (
+    "context"
     "github.com/jmervine/goperf"
+    "github.com/jmervine/goperf/connector"
+    "github.com/jmervine/goperf/results"
     "flag"
     "os"
+    "os/signal"
+    "path/filepath"
     "fmt"
+    "strconv"
+    "strings"
+    "time"
 )
 
+// headers collects repeated -H flags.
+type headers []string
+
+func (h *headers) String() string {
+    return fmt.Sprint(*h)
+}
+
+func (h *headers) Set(value string) error {
+    *h = append(*h, value)
+    return nil
+}
+
+// codes collects -expect-status codes, comma separated or repeated.
+type codes []int
+
+func (c *codes) String() string {
+    return fmt.Sprint(*c)
+}
+
+func (c *codes) Set(value string) error {
+    for _, field := range strings.Split(value, ",") {
+        code, err := strconv.Atoi(strings.TrimSpace(field))
+        if err != nil {
+            return fmt.Errorf("invalid status code %q", field)
+        }
+        *c = append(*c, code)
+    }
+    return nil
+}
+
+// floats collects -buckets and -percentiles numbers, comma separated or
+// repeated.
+type floats []float64
+
+func (f *floats) String() string {
+    return fmt.Sprint(*f)
+}
+
+func (f *floats) Set(value string) error {
+    for _, field := range strings.Split(value, ",") {
+        n, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+        if err != nil {
+            return fmt.Errorf("invalid number %q", field)
+        }
+        *f = append(*f, n)
+    }
+    return nil
+}
+
+// names collects -ciphers names, comma separated or repeated.
+type names []string
+
+func (n *names) String() string {
+    return fmt.Sprint(*n)
+}
+
+func (n *names) Set(value string) error {
+    for _, field := range strings.Split(value, ",") {
+        *n = append(*n, strings.TrimSpace(field))
+    }
+    return nil
+}
+
+// thresholds collects repeated -threshold flags.
+type thresholds []results.Threshold
+
+func (t *thresholds) String() string {
+    return fmt.Sprint(*t)
+}
+
+func (t *thresholds) Set(value string) error {
+    threshold, err := results.ParseThreshold(value)
+    if err != nil {
+        return err
+    }
+    *t = append(*t, threshold)
+    return nil
+}
+
 var (
     path string
     conns int
     rate float64
+    arrival string
+    seed int64
+    duration time.Duration
+    concurrency int
+    keepalive bool
+    connsperhost int
+    timeout time.Duration
+    connecttimeout time.Duration
+    headertimeout time.Duration
     verbose bool
     version bool
+    method string
+    header headers
+    data string
+    output string
+    raw bool
+    logfile string
+    urlfile string
+    selection string
+    sessionfile string
+    cookies bool
+    expectstatus codes
+    expectbody string
+    expectregex string
+    expectheader string
+    maxsize int64
+    consume string
+    protocol string
+    cafile string
+    certfile string
+    keyfile string
+    insecure bool
+    servername string
+    tlsmin string
+    tlsmax string
+    ciphers names
+    keepbytes int64
+    threshold thresholds
+    thresholdfile string
+    bucket floats
+    percentiles floats
+    precision int
+    discard bool
 )
 
 func init() {
+    // "goperf compare" has flags of its own, see compare.
+    if len(os.Args) > 1 && os.Args[1] == "compare" {
+        return
+    }
+
     // config.Path
     //flag.StringVar(&path , "path" , "" , "path")
     flag.StringVar(&path , "u"    , "" , "Target URL.")
@@ -27,10 +160,95 @@ func init() {
     // config.Rate
     flag.Float64Var(&rate , "r"    , 0 , "Connection rate (per second).")
 
+    // config.Arrival, config.Seed
+    flag.StringVar(&arrival , "arrival" , "" , "Arrival process with -r, constant, poisson, uniform[:MIN,MAX] or burst[:N] (default constant).")
+    flag.Int64Var(&seed     , "seed"    , 0  , "Random seed for -arrival and -select random (0 seeds from the clock).")
+
+    // config.Duration
+    flag.DurationVar(&duration , "t" , 0 , "Test duration, e.g. 60s (stops at -n or -t, whichever is first).")
+
+    // config.URLFile, config.Selection
+    flag.StringVar(&urlfile , "urls" , "" , "URL list file, one \"[METHOD] URL [weight=N] [body=DATA|@file]\" per line.")
+    flag.StringVar(&selection , "select" , "roundrobin" , "URL selection with -urls, roundrobin, random or weighted.")
+
+    // config.SessionFile
+    flag.StringVar(&sessionfile , "sessions" , "" , "Session file, see connector.ReadSessions (-n, -r and -c then apply to sessions).")
+
+    // config.Cookies
+    flag.BoolVar(&cookies , "cookies" , false , "Keep cookies per virtual user (session, or -c worker).")
+
+    // config.ExpectStatus, config.ExpectBody, config.ExpectPattern,
+    // config.ExpectHeader, config.MaxSize
+    flag.Var(&expectstatus , "expect-status" , "Fail responses without one of these status codes, e.g. 200,204.")
+    flag.StringVar(&expectbody , "expect-body" , "" , "Fail responses whose body does not contain this text.")
+    flag.StringVar(&expectregex , "expect-regex" , "" , "Fail responses whose body does not match this regexp.")
+    flag.StringVar(&expectheader , "expect-header" , "" , "Fail responses without this header, 'Name' or 'Name: value'.")
+    flag.Int64Var(&maxsize , "max-size" , 0 , "Fail responses with a body larger than this, in bytes.")
+
+    // config.Consume, config.KeepBytes
+    flag.StringVar(&consume , "consume" , "" , "Response bodies, discard, hash (SHA-256, in -log) or keep (default discard, keep with -expect-body or -expect-regex).")
+    flag.Int64Var(&keepbytes , "keep-bytes" , 0 , "Bytes of each body kept for checks with -consume=keep (0 keeps all).")
+
+    // config.CAFile, config.CertFile, config.KeyFile, config.Insecure,
+    // config.ServerName, config.TLSMin, config.TLSMax, config.Ciphers
+    flag.StringVar(&cafile , "cacert" , "" , "Trusted CA bundle (PEM), in place of the system roots.")
+    flag.StringVar(&certfile , "cert" , "" , "Client certificate (PEM), with -key.")
+    flag.StringVar(&keyfile , "key" , "" , "Client certificate key (PEM), with -cert.")
+    flag.BoolVar(&insecure , "insecure" , false , "Skip TLS certificate verification.")
+    flag.StringVar(&servername , "servername" , "" , "TLS server name (SNI) and verified name, in place of the URL host.")
+    flag.StringVar(&tlsmin , "tls-min" , "" , "Minimum TLS version, 1.0 to 1.3.")
+    flag.StringVar(&tlsmax , "tls-max" , "" , "Maximum TLS version, 1.0 to 1.3.")
+    flag.Var(&ciphers , "ciphers" , "TLS 1.0 to 1.2 cipher suites, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,...")
+
+    // config.Buckets
+    flag.Var(&bucket , "buckets" , "Histogram bucket bounds in ms, e.g. 10,50,100 (default log-linear).")
+
+    // config.Percentiles, config.Precision, config.Discard
+    flag.Var(&percentiles , "percentiles" , "Percentiles to report, e.g. 50,75,99,99.9,99.99 (default 85,90,95,99).")
+    flag.IntVar(&precision , "precision" , results.DefaultPrecision , "Percentile precision, in significant figures (1 to 5).")
+    flag.BoolVar(&discard , "discard" , false , "Keep no raw samples, for bounded memory on long runs.")
+
+    // thresholds, checked after the run
+    flag.Var(&threshold , "threshold" , "Exit 3 unless met, e.g. 'p99<250ms', 'errors<1%' or 'rps>500' (repeatable).")
+    flag.StringVar(&thresholdfile , "thresholds" , "" , "Threshold file, one -threshold rule per line.")
+
+    // config.Concurrency
+    flag.IntVar(&concurrency , "c" , 0 , "Concurrency, keep this many requests in flight (ignores -r).")
+
+    // config.KeepAlive
+    flag.BoolVar(&keepalive , "keep-alive" , false , "Reuse connections, rather than one connection per request.")
+
+    // config.ConnsPerHost
+    flag.IntVar(&connsperhost , "conns-per-host" , 0 , "Max connections per host with -keep-alive (0 is unlimited), or connections with -proto h2 or h2c.")
+
+    // config.Protocol
+    flag.StringVar(&protocol , "proto" , connector.HTTP1 , "Protocol, http1.1, h2 or h2c (HTTP/2 multiplexes over -conns-per-host connections, default 1).")
+
+    // config.Timeout, config.ConnectTimeout, config.HeaderTimeout
+    flag.DurationVar(&timeout , "timeout" , 0 , "Overall request timeout, e.g. 10s.")
+    flag.DurationVar(&connecttimeout , "connect-timeout" , 0 , "Connect (and TLS handshake) timeout, e.g. 2s.")
+    flag.DurationVar(&headertimeout , "header-timeout" , 0 , "Response header timeout, e.g. 5s.")
+
+    // config.Method
+    flag.StringVar(&method , "X" , "" , "Request method (default GET, or POST when -d is set).")
+
+    // config.Headers
+    flag.Var(&header , "H" , "Request header, e.g. 'Accept: text/html' (repeatable).")
+
+    // config.Body, config.BodyFile
+    flag.StringVar(&data , "d" , "" , "Request body, use '@file' to read the body from a file.")
+
     // config.Verbose
     //flag.BoolVar(&verbose , "verbose" , false , "verbose")
     flag.BoolVar(&verbose , "v"       , false , "Print verbose messaging.")
 
+    // output
+    flag.StringVar(&output , "o" , "text" , "Output format, text or json.")
+    flag.BoolVar(&raw , "raw" , false , "Include raw samples in json output.")
+
+    // config.Log
+    flag.StringVar(&logfile , "log" , "" , "Write a per-request log, as JSON Lines for .jsonl files, otherwise CSV.")
+
     flag.BoolVar(&version , "version", false , "Show version infomration.")
 
     flag.Parse()
@@ -40,18 +258,144 @@ func init() {
         os.Exit(0)
     }
 
-    if path == "" || conns == 0 {
+    if output != "text" && output != "json" {
+        fmt.Fprintf(os.Stderr, "Unknown output format %q.\n", output)
+        flag.Usage()
+        os.Exit(1)
+    }
+
+    if (path == "" && urlfile == "" && sessionfile == "") || (conns == 0 && duration == 0) {
         flag.Usage()
         os.Exit(0)
     }
 }
 
 func main() {
+    if len(os.Args) > 1 && os.Args[1] == "compare" {
+        compare(os.Args[2:])
+        return
+    }
+
     config := &perf.Configurator{
         Path: path, NumConns: conns, Rate: rate, Verbose: verbose,
+        Duration: duration, Concurrency: concurrency, Arrival: arrival, Seed: seed,
+        KeepAlive: keepalive, ConnsPerHost: connsperhost, Protocol: protocol,
+        Timeout: timeout, ConnectTimeout: connecttimeout, HeaderTimeout: headertimeout,
+        Quiet: output == "json",
+        Method: method, Headers: header,
+        URLFile: urlfile, Selection: selection,
+        SessionFile: sessionfile, Cookies: cookies,
+        ExpectStatus: expectstatus, ExpectBody: expectbody, ExpectPattern: expectregex,
+        ExpectHeader: expectheader, MaxSize: maxsize,
+        Consume: consume, KeepBytes: keepbytes,
+        CAFile: cafile, CertFile: certfile, KeyFile: keyfile, Insecure: insecure,
+        ServerName: servername, TLSMin: tlsmin, TLSMax: tlsmax, Ciphers: ciphers,
+        Buckets: bucket, Percentiles: percentiles, Precision: precision, Discard: discard,
     }
 
-    results := perf.Start(config)
-    perf.Display(results)
+    if strings.HasPrefix(data, "@") {
+        config.BodyFile = strings.TrimPrefix(data, "@")
+    } else {
+        config.Body = data
+    }
+
+    if thresholdfile != "" {
+        rules, err := results.LoadThresholds(thresholdfile)
+        if err != nil {
+            fail(err)
+        }
+        threshold = append(threshold, rules...)
+    }
+
+    if logfile != "" {
+        file, err := os.Create(logfile)
+        if err != nil {
+            fail(err)
+        }
+        defer file.Close()
+
+        if filepath.Ext(logfile) == ".jsonl" {
+            config.Log = results.NewJSONLWriter(file)
+        } else {
+            config.Log = results.NewCSVWriter(file)
+        }
+    }
+
+    // Stop on Ctrl-C, still displaying what completed. A second Ctrl-C
+    // exits immediately.
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+    go func() {
+        <-ctx.Done()
+        stop()
+    }()
+
+    rs, err := perf.StartContext(ctx, config)
+    stop()
+    if err != nil {
+        fail(err)
+    }
+
+    if config.Log != nil {
+        if err := config.Log.Flush(); err != nil {
+            fail(err)
+        }
+    }
+
+    if output == "json" {
+        if err := perf.DisplayJSON(rs, raw); err != nil {
+            fail(err)
+        }
+    } else {
+        perf.Display(rs)
+    }
+
+    // Breached thresholds are reported on stderr, keeping json output
+    // parsable, and exit 3 as 1 is an error and 2 a usage error.
+    if violations := rs.Evaluate(threshold); len(violations) > 0 {
+        for _, v := range violations {
+            fmt.Fprintf(os.Stderr, "Threshold breached: %s\n", v)
+        }
+        os.Exit(3)
+    }
 }
 
+
+// compare diffs two results saved with -o json, exiting 3 when there
+// are regressions beyond the tolerance.
+func compare(args []string) {
+    flags := flag.NewFlagSet("compare", flag.ExitOnError)
+    tolerance := flags.Float64("tolerance", 5, "Flag changes beyond this percentage.")
+    flags.Usage = func() {
+        fmt.Fprintf(os.Stderr, "Usage of %s compare [flags] old.json new.json:\n", os.Args[0])
+        flags.PrintDefaults()
+    }
+    flags.Parse(args)
+
+    if flags.NArg() != 2 {
+        flags.Usage()
+        os.Exit(2)
+    }
+
+    old, err := results.LoadReport(flags.Arg(0))
+    if err != nil {
+        fail(err)
+    }
+
+    new, err := results.LoadReport(flags.Arg(1))
+    if err != nil {
+        fail(err)
+    }
+
+    comparison := results.Compare(old, new, *tolerance)
+    perf.DisplayComparison(comparison)
+
+    if len(comparison.Regressions()) > 0 {
+        os.Exit(3)
+    }
+}
+
+// fail reports err and exits non-zero.
+func fail(err error) {
+    fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+    os.Exit(1)
+}
@@ -0,0 +1,150 @@
+package connector
+
+import (
+    "fmt"
+    "math/rand"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Arrival is an open model arrival process, spacing request (or session)
+// starts in Parallel runs regardless of how earlier requests fare, see
+// Connector.Arrival.
+type Arrival interface {
+    // Next returns the time from the previous arrival to the next.
+    Next() time.Duration
+}
+
+// Constant arrivals are evenly spaced, at Rate per second.
+type Constant struct {
+    Rate float64
+}
+
+// Poisson arrivals are a Poisson process at a mean of Rate per second,
+// i.e. with exponentially distributed gaps. Rand, when set, makes them
+// reproducible.
+type Poisson struct {
+    Rate float64
+    Rand *rand.Rand
+}
+
+// Uniform arrivals have gaps uniformly distributed from Min to Max.
+// Rand, when set, makes them reproducible.
+type Uniform struct {
+    Min  time.Duration
+    Max  time.Duration
+    Rand *rand.Rand
+}
+
+// Burst arrivals come Size at once, with bursts spaced for a mean of
+// Rate per second.
+type Burst struct {
+    Rate float64
+    Size int
+
+    n int
+}
+
+// DefaultBurst is the Burst Size used by ParseArrival.
+const DefaultBurst = 10
+
+// ParseArrival parses an arrival process for rate per second, as one of:
+//
+//	constant          evenly spaced
+//	poisson           exponential gaps
+//	uniform           gaps from 0 to twice the mean
+//	uniform:MIN,MAX   gaps from MIN to MAX, e.g. uniform:50ms,150ms
+//	burst[:N]         bursts of N (DefaultBurst) at once
+//
+// r seeds the random processes, a clock-seeded source is used when nil.
+func ParseArrival(spec string, rate float64, r *rand.Rand) (Arrival, error) {
+    name, args, _ := strings.Cut(strings.TrimSpace(spec), ":")
+
+    if rate <= 0 && !(name == "uniform" && args != "") {
+        return nil, fmt.Errorf("invalid arrival %q, requires a rate", spec)
+    }
+
+    switch name {
+    case "constant":
+        if args == "" {
+            return &Constant{Rate: rate}, nil
+        }
+    case "poisson":
+        if args == "" {
+            return &Poisson{Rate: rate, Rand: r}, nil
+        }
+    case "uniform":
+        if args == "" {
+            return &Uniform{Max: time.Duration(2 / rate * float64(time.Second)), Rand: r}, nil
+        }
+
+        min, max, ok := strings.Cut(args, ",")
+        lo, err := time.ParseDuration(strings.TrimSpace(min))
+        if err != nil || !ok {
+            break
+        }
+
+        hi, err := time.ParseDuration(strings.TrimSpace(max))
+        if err != nil || lo < 0 || hi < lo {
+            break
+        }
+        return &Uniform{Min: lo, Max: hi, Rand: r}, nil
+    case "burst":
+        size := DefaultBurst
+        if args != "" {
+            n, err := strconv.Atoi(args)
+            if err != nil || n < 1 {
+                break
+            }
+            size = n
+        }
+        return &Burst{Rate: rate, Size: size}, nil
+    default:
+        return nil, fmt.Errorf("invalid arrival %q, expected constant, poisson, uniform or burst", spec)
+    }
+
+    return nil, fmt.Errorf("invalid arrival %q, bad arguments %q", spec, args)
+}
+
+// Next returns 1/Rate.
+func (a *Constant) Next() time.Duration {
+    return seconds(1 / a.Rate)
+}
+
+// Next returns an exponentially distributed gap, of mean 1/Rate.
+func (a *Poisson) Next() time.Duration {
+    a.Rand = source(a.Rand)
+    return seconds(a.Rand.ExpFloat64() / a.Rate)
+}
+
+// Next returns a gap from Min to Max.
+func (a *Uniform) Next() time.Duration {
+    a.Rand = source(a.Rand)
+    return a.Min + time.Duration(a.Rand.Int63n(int64(a.Max-a.Min)+1))
+}
+
+// Next returns zero within a burst, and Size/Rate between bursts.
+func (a *Burst) Next() time.Duration {
+    a.n++
+    if a.n%a.Size != 0 {
+        return 0
+    }
+    return seconds(float64(a.Size) / a.Rate)
+}
+
+/****
+ * Private methods
+ *****************************************************/
+
+func seconds(s float64) time.Duration {
+    return time.Duration(s * float64(time.Second))
+}
+
+// source returns r, or a clock-seeded source when nil.
+func source(r *rand.Rand) *rand.Rand {
+    if r == nil {
+        return rand.New(rand.NewSource(time.Now().UnixNano()))
+    }
+    return r
+}
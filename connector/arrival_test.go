@@ -0,0 +1,86 @@
+package connector
+
+import (
+    "math/rand"
+    "testing"
+    "time"
+)
+
+func TestArrival(T *testing.T) {
+    constant := &Constant{Rate: 4}
+    Go(T).AssertEqual(constant.Next(), 250*time.Millisecond)
+
+    // Seeded arrivals repeat.
+    first := &Poisson{Rate: 100, Rand: rand.New(rand.NewSource(42))}
+    second := &Poisson{Rate: 100, Rand: rand.New(rand.NewSource(42))}
+
+    var total time.Duration
+    for i := 0; i < 2000; i++ {
+        gap := first.Next()
+        Go(T).AssertEqual(gap, second.Next())
+        Go(T).Assert(gap >= 0)
+        total += gap
+    }
+
+    // A mean of 10ms, give or take.
+    mean := total / 2000
+    Go(T).Assert(mean > 9*time.Millisecond && mean < 11*time.Millisecond, mean.String())
+
+    uniform := &Uniform{Min: 50 * time.Millisecond, Max: 150 * time.Millisecond, Rand: rand.New(rand.NewSource(1))}
+    total = 0
+    for i := 0; i < 2000; i++ {
+        gap := uniform.Next()
+        Go(T).Assert(gap >= uniform.Min && gap <= uniform.Max, gap.String())
+        total += gap
+    }
+
+    mean = total / 2000
+    Go(T).Assert(mean > 95*time.Millisecond && mean < 105*time.Millisecond, mean.String())
+
+    // Unseeded arrivals still work.
+    Go(T).Assert((&Poisson{Rate: 10}).Next() >= 0)
+
+    // Bursts of 3, a burst every 300ms at 10 per second.
+    burst := &Burst{Rate: 10, Size: 3}
+    var gaps []time.Duration
+    for i := 0; i < 6; i++ {
+        gaps = append(gaps, burst.Next())
+    }
+
+    Go(T).AssertEqual(gaps, []time.Duration{0, 0, 300 * time.Millisecond, 0, 0, 300 * time.Millisecond})
+}
+
+func TestParseArrival(T *testing.T) {
+    arrival, err := ParseArrival("constant", 10, nil)
+    Go(T).AssertEqual(err, nil)
+    Go(T).AssertEqual(arrival, Arrival(&Constant{Rate: 10}))
+
+    arrival, err = ParseArrival("poisson", 10, nil)
+    Go(T).AssertEqual(err, nil)
+    Go(T).AssertEqual(arrival, Arrival(&Poisson{Rate: 10}))
+
+    arrival, err = ParseArrival("uniform", 10, nil)
+    Go(T).AssertEqual(err, nil)
+    Go(T).AssertEqual(arrival, Arrival(&Uniform{Max: 200 * time.Millisecond}))
+
+    // An explicit range needs no rate.
+    arrival, err = ParseArrival("uniform:50ms, 150ms", 0, nil)
+    Go(T).AssertEqual(err, nil)
+    Go(T).AssertEqual(arrival, Arrival(&Uniform{Min: 50 * time.Millisecond, Max: 150 * time.Millisecond}))
+
+    arrival, err = ParseArrival("burst", 10, nil)
+    Go(T).AssertEqual(err, nil)
+    Go(T).AssertEqual(arrival, Arrival(&Burst{Rate: 10, Size: DefaultBurst}))
+
+    arrival, err = ParseArrival("burst:5", 10, nil)
+    Go(T).AssertEqual(err, nil)
+    Go(T).AssertEqual(arrival, Arrival(&Burst{Rate: 10, Size: 5}))
+
+    _, err = ParseArrival("poisson", 0, nil)
+    Go(T).RefuteEqual(err, nil)
+
+    for _, spec := range []string{"gamma", "constant:1", "uniform:1s", "uniform:2s,1s", "uniform:-1s,1s", "burst:0", "burst:x"} {
+        _, err = ParseArrival(spec, 10, nil)
+        Go(T).RefuteEqual(err, nil)
+    }
+}
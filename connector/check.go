@@ -0,0 +1,86 @@
+package connector
+
+import (
+    "bytes"
+    "fmt"
+    "github.com/jmervine/goperf/results"
+    "net/http"
+    "regexp"
+    "strings"
+)
+
+// Check validates responses, so that e.g. a 200 with an error page is
+// not counted as a success. Each set field is a rule, all of which must
+// pass. Failures are Result errors wrapping results.ErrCheckFailed.
+type Check struct {
+    // Status lists the accepted status codes.
+    Status []int
+
+    // Body must be contained in, and Pattern match, the response body.
+    Body    string
+    Pattern *regexp.Regexp
+
+    // Header is a required header, either "Name" to require its presence
+    // or "Name: value" to require its value.
+    Header string
+
+    // MaxSize limits the response body, in bytes.
+    MaxSize int64
+}
+
+// Validate checks a response, returning the first failed rule. Body is
+// the part of the body kept, see Connector.Consume, and size its full
+// size in bytes.
+func (c *Check) Validate(code int, header http.Header, body []byte, size int64) error {
+    if len(c.Status) > 0 && !c.status(code) {
+        return c.fail("unexpected status %d", code)
+    }
+
+    if c.Header != "" {
+        name, value, exact := strings.Cut(c.Header, ":")
+        got, ok := header[http.CanonicalHeaderKey(strings.TrimSpace(name))]
+        if !ok {
+            return c.fail("missing header %q", strings.TrimSpace(name))
+        }
+
+        if exact && (len(got) == 0 || got[0] != strings.TrimSpace(value)) {
+            return c.fail("unexpected header %q", c.Header)
+        }
+    }
+
+    if c.MaxSize > 0 && size > c.MaxSize {
+        return c.fail("body of %d bytes exceeds %d", size, c.MaxSize)
+    }
+
+    if c.Body != "" && !bytes.Contains(body, []byte(c.Body)) {
+        return c.fail("body does not contain %q", c.Body)
+    }
+
+    if c.Pattern != nil && !c.Pattern.Match(body) {
+        return c.fail("body does not match %q", c.Pattern.String())
+    }
+
+    return nil
+}
+
+/****
+ * Private methods
+ *****************************************************/
+
+// needsBody reports whether rules read the body, so it must be kept.
+func (c *Check) needsBody() bool {
+    return c.Body != "" || c.Pattern != nil
+}
+
+func (c *Check) status(code int) bool {
+    for _, status := range c.Status {
+        if status == code {
+            return true
+        }
+    }
+    return false
+}
+
+func (c *Check) fail(format string, args ...interface{}) error {
+    return fmt.Errorf("%w: %s", results.ErrCheckFailed, fmt.Sprintf(format, args...))
+}
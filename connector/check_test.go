@@ -0,0 +1,43 @@
+package connector
+
+import (
+    "errors"
+    "net/http"
+    "regexp"
+    "testing"
+    "github.com/jmervine/goperf/results"
+)
+
+func TestCheckValidate(T *testing.T) {
+    header := http.Header{"Content-Type": []string{"text/html"}}
+    body := []byte("<h1>Dashboard</h1>")
+
+    Go(T).Assert((&Check{}).Validate(500, nil, nil, 0) == nil)
+
+    for _, check := range []*Check{
+        {Status: []int{200, 204}},
+        {Body: "Dashboard"},
+        {Pattern: regexp.MustCompile(`<h1>\w+</h1>`)},
+        {Header: "content-type"},
+        {Header: "Content-Type: text/html"},
+        {MaxSize: 18},
+    } {
+        Go(T).Assert(check.Validate(200, header, body, int64(len(body))) == nil)
+    }
+
+    for _, check := range []*Check{
+        {Status: []int{201}},
+        {Body: "Error"},
+        {Pattern: regexp.MustCompile(`^Error`)},
+        {Header: "X-Request-Id"},
+        {Header: "Content-Type: application/json"},
+        {MaxSize: 10},
+    } {
+        err := check.Validate(200, header, body, int64(len(body)))
+        Go(T).Refute(err == nil)
+        Go(T).Assert(errors.Is(err, results.ErrCheckFailed))
+    }
+
+    // Size is the full body, which may be more than was kept.
+    Go(T).Refute((&Check{MaxSize: 18}).Validate(200, header, body[:4], 1024) == nil)
+}
@@ -1,11 +1,19 @@
 package connector
 
 import (
+    "bytes"
+    "context"
+    "crypto/tls"
+    "errors"
     "fmt"
+    "io"
+    "math/rand"
     "net"
     "net/http"
-    "net/http/httputil"
+    "net/http/cookiejar"
+    "net/http/httptrace"
     "net/url"
+    "os"
     "sync"
     "time"
     "github.com/jmervine/goperf/results"
@@ -14,168 +22,839 @@ import (
 // Connector contains connector data.
 type Connector struct {
     waiter *sync.WaitGroup
-    tranny chan results.Result
+    lock   *sync.Mutex
+    client *http.Client
+    picked int
+    rng    *rand.Rand
+
+    // requested counts requests issued in the current run, and indexes
+    // their results.
+    requested int
 
     Path     string
+    Method   string
+    Header   http.Header
+    Body     []byte
     NumConns int
     Rate     float64
     Verbose  bool
     Results  *results.Results
+
+    // Messages receives Verbose messaging, os.Stdout when nil, e.g.
+    // os.Stderr to keep stdout for machine readable output.
+    Messages io.Writer
+
+    // Duration limits a run by time rather than by NumConns, when both
+    // are set the run stops at whichever limit is reached first.
+    Duration time.Duration
+
+    // Concurrency is the number of workers used by Pool, each issuing
+    // requests back-to-back. Rate is ignored by Pool.
+    Concurrency int
+
+    // Arrival, when set, spaces Parallel requests (or sessions) instead
+    // of Rate, e.g. as a Poisson process, see ParseArrival. Without it,
+    // Rate spaces them evenly.
+    Arrival Arrival
+
+    // Seed, when not zero, seeds random Selection so runs are
+    // reproducible, otherwise it is seeded from the clock.
+    Seed int64
+
+    // KeepAlive reuses connections between requests, with up to
+    // ConnsPerHost connections per host (zero for no limit). When false,
+    // every request opens a fresh TCP connection.
+    KeepAlive    bool
+    ConnsPerHost int
+
+    // Protocol selects HTTP1 (the default), HTTP2 over TLS, or H2C, i.e.
+    // HTTP/2 without TLS. With HTTP2 and H2C requests are multiplexed as
+    // streams over ConnsPerHost connections (one when zero), and
+    // KeepAlive is implied. More are only opened while streams on each
+    // are at the server's limit (typically 100 or more). Replies not
+    // using HTTP/2 are errors, see ErrUnexpectedProtocol.
+    Protocol string
+
+    // Timeout limits each request overall, including reading the body.
+    // ConnectTimeout limits dialing and the TLS handshake, HeaderTimeout
+    // limits waiting for response headers once the request is written.
+    // Zero means no limit.
+    Timeout        time.Duration
+    ConnectTimeout time.Duration
+    HeaderTimeout  time.Duration
+
+    // Targets, when set, replace Method, Path and Body with a multi-URL
+    // workload, chosen per request by Selection (RoundRobin by default).
+    // Results are broken down per target in Results.Targets.
+    Targets   []Target
+    Selection string
+
+    // Sessions, when set, replace single requests with session workloads
+    // (see Session). NumConns, Rate and Concurrency then apply to
+    // sessions, e.g. with Rate sessions are started at Rate per second.
+    // Users take Sessions in turn.
+    Sessions []Session
+
+    // Cookies gives each virtual user its own cookie jar, so cookies set
+    // by a response are sent with that user's later requests, e.g. to
+    // log in once per session. A user is a Session in session workloads,
+    // otherwise a Pool worker, or the whole run for Series. Parallel
+    // requests are each a new user.
+    Cookies bool
+
+    // Check, when set, validates each response. Failed checks are
+    // counted as errors, see results.ErrCheckFailed.
+    Check *Check
+
+    // TLS, when set, configures TLS connections, e.g. trusted CAs, client
+    // certificates, SNI or version and cipher pinning. A session cache is
+    // added unless set, so handshakes on new connections may resume.
+    TLS *tls.Config
+
+    // Consume sets how response bodies are read, each streamed to EOF
+    // without holding it: Discard, Hash (SHA-256, see Result.Digest) or
+    // Keep, keeping the first KeepBytes (all when zero) for Check. By
+    // default bodies are discarded, or kept when Check has body rules.
+    Consume   string
+    KeepBytes int64
+
+    // Log, when set, receives a Record for each request as it is added
+    // to Results, and is flushed when the run is finalized.
+    Log results.Writer
 }
 
-// New generates a new Connector with all the necessaries.
-func (conn Connector) New(path string, numconns int) Connector {
+// maxPresize limits the samples allocated up front by New.
+const maxPresize = 1 << 16
+
+// Errors returned by New for unusable paths.
+var (
+    ErrMissingHost       = errors.New("missing host")
+    ErrUnsupportedScheme = errors.New("unsupported scheme")
+)
+
+// ErrUnexpectedProtocol wraps errors of replies not using HTTP/2 when
+// Protocol is HTTP2 or H2C.
+var ErrUnexpectedProtocol = errors.New("unexpected protocol")
+
+// New generates a new Connector with all the necessaries, returning a
+// *url.Error when path cannot be used.
+func New(path string, numconns int) (Connector, error) {
+    conn := Connector{}
+
     uri, err := url.Parse(path)
+    if err != nil {
+        return conn, err
+    }
 
     if uri.Scheme == "" {
         uri.Scheme = "http"
     }
 
-    if err != nil {
-        panic(err)
+    if uri.Scheme != "http" && uri.Scheme != "https" {
+        return conn, &url.Error{Op: "parse", URL: path, Err: ErrUnsupportedScheme}
+    }
+
+    if uri.Host == "" {
+        return conn, &url.Error{Op: "parse", URL: path, Err: ErrMissingHost}
     }
 
     conn.Path = uri.String()
+    conn.Method = "GET"
+    conn.Header = http.Header{}
     conn.NumConns = numconns
     conn.waiter = &sync.WaitGroup{}
-    conn.tranny = make(chan results.Result)
+    conn.lock = &sync.Mutex{}
+
+    // Results grow beyond maxPresize, so huge runs don't allocate
+    // samples up front.
+    presize := numconns
+    if presize > maxPresize {
+        presize = maxPresize
+    }
 
     conn.Results = &results.Results{
-        Took: make([]float64, numconns),
-        Code: make([]int, numconns),
+        Took: make([]float64, 0, presize),
+        Code: make([]int, 0, presize),
 
         // set to -1 so that it gets the first connection time
         ConnectTime: -1,
     }
 
-    return conn
+    return conn, nil
 }
 
-// Run runs the Connector, selecting Parallel or Series based on Rate.
+// New generates a new Connector with all the necessaries, panicking
+// when path cannot be used. See New (the function) for errors.
+func (conn Connector) New(path string, numconns int) Connector {
+    c, err := New(path, numconns)
+    if err != nil {
+        panic(err)
+    }
+
+    return c
+}
+
+// Run runs the Connector, selecting Pool when Concurrency is set,
+// otherwise Parallel or Series based on Rate and Arrival.
 func (conn *Connector) Run() {
-    if conn.Rate != 0 {
-        conn.Parallel()
+    conn.RunContext(context.Background())
+}
+
+// RunContext is Run, stopping early when ctx is cancelled. See
+// SeriesContext.
+func (conn *Connector) RunContext(ctx context.Context) {
+    if conn.Concurrency > 0 {
+        conn.PoolContext(ctx)
+    } else if conn.Rate != 0 || conn.Arrival != nil {
+        conn.ParallelContext(ctx)
     } else {
-        conn.Series()
+        conn.SeriesContext(ctx)
     }
 }
 
 // Series runs the Connector serialized.
 func (conn *Connector) Series() {
-    start := time.Now()
+    conn.SeriesContext(context.Background())
+}
 
-    defer conn.finalize(start)
+// SeriesContext is Series, stopping early when ctx is cancelled. Requests
+// in flight are aborted, and counted as canceled errors. Results are
+// finalized with what completed, and marked Interrupted.
+func (conn *Connector) SeriesContext(ctx context.Context) {
+    start := conn.begin()
 
-    for i := 0; i < conn.NumConns; i++ {
-        result := conn.Connect()
-        result.Index = i
-        conn.Results.Add(result)
+    defer conn.finalize(ctx, start)
+
+    conn.Results.Concurrency = 1
+    client := conn.user()
+
+    for i := 0; conn.more(ctx, i, start); i++ {
+        conn.work(ctx, client, i, time.Time{})
     }
 }
 
 // Parallel runs the Connector parallelized.
 func (conn *Connector) Parallel() {
-    start := time.Now()
+    conn.ParallelContext(context.Background())
+}
 
-    defer conn.finalize(start)
+// ParallelContext is Parallel, stopping early when ctx is cancelled. See
+// SeriesContext. Unpaced (without Rate or Arrival) timed runs would start
+// requests without bound until Duration expires, so run in Series.
+func (conn *Connector) ParallelContext(ctx context.Context) {
+    arrival := conn.Arrival
+    if arrival == nil && conn.Rate > 0 {
+        arrival = &Constant{Rate: conn.Rate}
+    }
+
+    if arrival == nil && conn.Duration > 0 {
+        conn.SeriesContext(ctx)
+        return
+    }
 
-    for i := 0; i < conn.NumConns; i++ {
+    start := conn.begin()
 
-        if conn.Rate > 0 && i != 0 {
-            time.Sleep(time.Duration((1 / conn.Rate) * float64(time.Second)))
+    defer conn.finalize(ctx, start)
+
+    next := start
+    for i := 0; conn.more(ctx, i, start); i++ {
+
+        // Requests are sent on a schedule from start, rather than an
+        // interval after the last, so falling behind is caught up and
+        // the delay counted, see results.Result.Intended.
+        var intended time.Time
+        if arrival != nil {
+            if i != 0 {
+                next = next.Add(arrival.Next())
+            }
+            intended = next
+        }
+
+        if arrival != nil && i != 0 {
+            conn.sleep(ctx, time.Until(intended))
+
+            // Duration may have expired, or ctx been cancelled, while
+            // sleeping.
+            if !conn.more(ctx, i, start) {
+                break
+            }
         }
 
         conn.waiter.Add(1)
-        go func(i int) {
-            result := conn.Connect()
-            result.Index = i
-            conn.tranny <- result
-            conn.waiter.Done()
-        }(i)
+        go func(i int, intended time.Time) {
+            defer conn.waiter.Done()
+            conn.work(ctx, conn.user(), i, intended)
+        }(i, intended)
+    }
 
+    conn.waiter.Wait()
+}
+
+// Pool runs the Connector with a fixed number of workers (Concurrency),
+// keeping that many requests in flight until NumConns or Duration is
+// reached.
+func (conn *Connector) Pool() {
+    conn.PoolContext(context.Background())
+}
+
+// PoolContext is Pool, stopping early when ctx is cancelled. See
+// SeriesContext.
+func (conn *Connector) PoolContext(ctx context.Context) {
+    start := conn.begin()
+    claimed := 0
+
+    defer conn.finalize(ctx, start)
+
+    workers := conn.Concurrency
+    if workers < 1 {
+        workers = 1
+    }
+    conn.Results.Concurrency = workers
+
+    for w := 0; w < workers; w++ {
         conn.waiter.Add(1)
-        go conn.collect()
+        go func() {
+            defer conn.waiter.Done()
+            client := conn.user()
+
+            for {
+                i, ok := conn.claim(ctx, &claimed, start)
+                if !ok {
+                    return
+                }
+
+                conn.work(ctx, client, i, time.Time{})
+            }
+        }()
     }
 
     conn.waiter.Wait()
 }
 
-func (conn *Connector) customDial(network, addr string) (net.Conn, error) {
+func (conn *Connector) customDial(ctx context.Context, network, addr string) (net.Conn, error) {
     start := time.Now()
-    c, err := net.Dial(network, addr)
+    dialer := &net.Dialer{Timeout: conn.ConnectTimeout}
+    c, err := dialer.DialContext(ctx, network, addr)
+
+    conn.lock.Lock()
+    if err == nil {
+        conn.Results.Connections++
+        c = &numbered{Conn: c, id: conn.Results.Connections}
+    }
 
     if conn.Results.ConnectTime == -1 {
         conn.Results.ConnectTime = float64(time.Since(start) / time.Millisecond)
     }
+    conn.lock.Unlock()
 
     return c, err
 }
 
 // Connect makes a single connection.
 func (conn *Connector) Connect() results.Result {
+    return conn.ConnectContext(context.Background())
+}
 
-    transport := http.Transport{
-        Dial: conn.customDial,
-    }
+// ConnectContext is Connect, aborting the request when ctx is cancelled.
+func (conn *Connector) ConnectContext(ctx context.Context) results.Result {
+    return conn.connect(ctx, conn.user(), conn.next())
+}
 
-    http.DefaultClient = &http.Client{
-        Transport: &transport,
-    }
+/****
+ * Private methods
+ *****************************************************/
 
+func (conn *Connector) connect(ctx context.Context, client *http.Client, target Target) results.Result {
+    tr := &trace{}
     start := time.Now()
-    resp, err := http.Get(conn.Path)
-    took := float64(time.Since(start) / time.Millisecond)
+    resp, err := conn.do(ctx, client, tr, target)
+    if err != nil && ctx.Err() != nil {
+        err = fmt.Errorf("%w: %v", results.ErrCanceled, err)
+    }
 
     var code int
-    var tlen, clen, hlen int64
+    var clen, hlen int64
+    var estimated bool
+    var content []byte
+    var digest string
 
     if err == nil {
         code = resp.StatusCode
-        clen = resp.ContentLength
 
-        if dump, e := httputil.DumpResponse(resp, true); e == nil {
-            tlen = int64(len(dump))
-            hlen = tlen - clen
+        // Bodies are streamed rather than held, see Consume, and counted
+        // as read, as ContentLength is -1 when unknown (e.g. chunked).
+        content, clen, digest, err = conn.consume(resp.Body)
+        resp.Body.Close()
+
+        // Header bytes (with any chunk framing and trailer) are counted
+        // as read for HTTP/1.x without TLS, and estimated otherwise.
+        wire, measured := tr.received()
+        if measured && resp.ProtoMajor == 1 && wire > clen {
+            hlen = wire - clen
+        } else {
+            hlen, estimated = headerSize(resp), true
+        }
+
+        // The Transport falls back to HTTP/1.1 when HTTP/2 isn't
+        // negotiated, e.g. for http URLs with HTTP2.
+        if err == nil && conn.multiplexed() && resp.ProtoMajor != 2 {
+            err = fmt.Errorf("%w: %s, expected HTTP/2", ErrUnexpectedProtocol, resp.Proto)
         }
     }
 
+    // Took covers the full exchange, to the last body byte.
+    end := time.Now()
+    took := ms(end.Sub(start))
+
+    if code > 0 && err == nil && conn.Check != nil {
+        err = conn.Check.Validate(code, resp.Header, content, clen)
+    }
+
     if conn.Verbose {
         if err != nil {
-            fmt.Printf(" > Responded with error: %q\n",
-                err.(*url.Error).Err.(*net.OpError).Error())
+            fmt.Fprintf(conn.messages(), " > Responded with error: %q\n", err.Error())
         } else {
-            fmt.Printf(" > Responded in %6.2f ms, with code: %d\n", took, code)
+            fmt.Fprintf(conn.messages(), " > Responded in %6.2f ms, with code: %d\n", took, code)
         }
     }
 
-    return results.Result{Took: took,
+    result := results.Result{Took: took,
+        Start:         start,
         Code:          code,
         Error:         err,
-        TotalLength:   tlen,
+        TotalLength:   hlen + clen,
         ContentLength: clen,
         HeaderLength:  hlen,
+        Timing:        tr.done(end),
+        Digest:        digest,
     }
+
+    result.HeaderEstimated = estimated
+
+    if resp != nil {
+        result.Proto = resp.Proto
+    }
+
+    // Connections are only tracked when reused, being one per request
+    // otherwise.
+    if conn.KeepAlive || conn.multiplexed() {
+        result.Conn = tr.connection()
+    }
+
+    if resp != nil && resp.TLS != nil {
+        result.TLSVersion = tls.VersionName(resp.TLS.Version)
+        result.TLSCipher = tls.CipherSuiteName(resp.TLS.CipherSuite)
+        result.TLSResumed = resp.TLS.DidResume
+    }
+
+    if len(conn.Targets) > 0 || len(conn.Sessions) > 0 {
+        result.Target = target.Name()
+    }
+
+    return result
 }
 
-/****
- * Private methods
- *****************************************************/
+// httpClient returns the Connector's client, creating it on first use.
+func (conn *Connector) httpClient() *http.Client {
+    conn.lock.Lock()
+    defer conn.lock.Unlock()
 
-func (conn *Connector) collect() {
-    tranny := <-conn.tranny
-    conn.Results.Add(tranny)
-    conn.waiter.Done()
+    if conn.client == nil {
+        conn.client = &http.Client{
+            Transport: conn.roundTripper(),
+            Timeout:   conn.Timeout,
+        }
+    }
+
+    return conn.client
+}
+
+// user returns a client for a new virtual user, with its own cookie jar
+// when Cookies is set. Users share the Connector's transport.
+func (conn *Connector) user() *http.Client {
+    client := conn.httpClient()
+    if !conn.Cookies {
+        return client
+    }
+
+    // cookiejar.New only errors on invalid Options.
+    jar, _ := cookiejar.New(nil)
+    return &http.Client{
+        Transport: client.Transport,
+        Timeout:   client.Timeout,
+        Jar:       jar,
+    }
+}
+
+// roundTripper returns the transport, or for HTTP/2 one per connection,
+// used in turn.
+func (conn *Connector) roundTripper() http.RoundTripper {
+    if !conn.multiplexed() {
+        return conn.transport()
+    }
+
+    n := conn.ConnsPerHost
+    if n == 0 {
+        n = 1
+    }
+
+    m := &multiplexer{}
+    for i := 0; i < n; i++ {
+        m.transports = append(m.transports, conn.transport())
+    }
+    return m
+}
+
+// multiplexed reports whether requests are streams on shared HTTP/2
+// connections.
+func (conn *Connector) multiplexed() bool {
+    return conn.Protocol == HTTP2 || conn.Protocol == H2C
 }
 
-func (conn *Connector) finalize(start time.Time) {
+func (conn *Connector) transport() *http.Transport {
+    config := &tls.Config{}
+    if conn.TLS != nil {
+        config = conn.TLS.Clone()
+    }
+
+    if config.ClientSessionCache == nil {
+        config.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+    }
+
+    // Compression is left to the request Header, so bodies are counted
+    // as sent rather than transparently decompressed.
+    transport := &http.Transport{
+        DialContext:           conn.customDial,
+        DisableKeepAlives:     !conn.KeepAlive,
+        DisableCompression:    true,
+        TLSClientConfig:       config,
+        TLSHandshakeTimeout:   conn.ConnectTimeout,
+        ResponseHeaderTimeout: conn.HeaderTimeout,
+    }
+
+    if conn.multiplexed() {
+        protocols := &http.Protocols{}
+        protocols.SetHTTP2(conn.Protocol == HTTP2)
+        protocols.SetUnencryptedHTTP2(conn.Protocol == H2C)
+
+        // For HTTP/2 this limits dials in progress, so concurrent first
+        // requests share a connection rather than each dialing one.
+        transport.Protocols = protocols
+        transport.DisableKeepAlives = false
+        transport.MaxConnsPerHost = 1
+        return transport
+    }
+
+    if conn.KeepAlive {
+        idle := conn.ConnsPerHost
+        if idle == 0 {
+            idle = conn.Concurrency
+        }
+
+        if idle < http.DefaultMaxIdleConnsPerHost {
+            idle = http.DefaultMaxIdleConnsPerHost
+        }
+
+        transport.MaxConnsPerHost = conn.ConnsPerHost
+        transport.MaxIdleConnsPerHost = idle
+    }
+
+    return transport
+}
+
+// do builds and sends the configured request via the Connector's client.
+func (conn *Connector) do(ctx context.Context, client *http.Client, tr *trace, target Target) (*http.Response, error) {
+    var body io.Reader
+    if len(target.Body) > 0 {
+        body = bytes.NewReader(target.Body)
+    }
+
+    req, err := http.NewRequestWithContext(ctx, target.Method, target.Path, body)
+    if err != nil {
+        return nil, err
+    }
+
+    for key, values := range conn.Header {
+        for _, value := range values {
+            req.Header.Add(key, value)
+        }
+    }
+
+    // Host must be set on the request, it is ignored in the header map.
+    if host := conn.Header.Get("Host"); host != "" {
+        req.Host = host
+    }
+
+    req = req.WithContext(httptrace.WithClientTrace(req.Context(), tr.clientTrace()))
+    return client.Do(req)
+}
+
+// next picks the Target for the next request, which is the Connector's
+// own Method, Path and Body unless Targets are set.
+func (conn *Connector) next() Target {
+    if len(conn.Targets) == 0 {
+        return Target{Method: conn.Method, Path: conn.Path, Body: conn.Body, Weight: 1}
+    }
+
+    conn.lock.Lock()
+    defer conn.lock.Unlock()
+
+    var target Target
+    switch conn.Selection {
+    case Random:
+        target = conn.Targets[conn.random().Intn(len(conn.Targets))]
+    case Weighted:
+        total := 0
+        for _, t := range conn.Targets {
+            total += t.Weight
+        }
+
+        n := conn.random().Intn(total)
+        for _, t := range conn.Targets {
+            if n -= t.Weight; n < 0 {
+                target = t
+                break
+            }
+        }
+    default:
+        target = conn.Targets[conn.picked%len(conn.Targets)]
+        conn.picked++
+    }
+
+    return conn.target(target)
+}
+
+// target resolves t's Path against the Connector's Path.
+func (conn *Connector) target(t Target) Target {
+    t.Path = conn.resolve(t.Path)
+    return t
+}
+
+// resolve path against the Connector's Path, leaving path as is when
+// either can't be parsed.
+func (conn *Connector) resolve(path string) string {
+    base, err := url.Parse(conn.Path)
+    if err != nil {
+        return path
+    }
+
+    ref, err := url.Parse(path)
+    if err != nil {
+        return path
+    }
+
+    return base.ResolveReference(ref).String()
+}
+
+// messages returns the writer for Verbose messaging.
+func (conn *Connector) messages() io.Writer {
+    if conn.Messages == nil {
+        return os.Stdout
+    }
+    return conn.Messages
+}
+
+// random returns the Connector's random source, expects lock to be held.
+func (conn *Connector) random() *rand.Rand {
+    if conn.rng == nil {
+        seed := conn.Seed
+        if seed == 0 {
+            seed = time.Now().UnixNano()
+        }
+        conn.rng = rand.New(rand.NewSource(seed))
+    }
+    return conn.rng
+}
+
+// more reports whether request (or session) i should be issued, given
+// NumConns and Duration limits and ctx. A zero limit is ignored.
+func (conn *Connector) more(ctx context.Context, i int, start time.Time) bool {
+    if ctx.Err() != nil {
+        return false
+    }
+
+    if conn.NumConns == 0 && conn.Duration == 0 {
+        return false
+    }
+
+    if conn.NumConns > 0 && i >= conn.NumConns {
+        return false
+    }
+
+    if conn.Duration > 0 && time.Since(start) >= conn.Duration {
+        return false
+    }
+
+    return true
+}
+
+// sleep waits for d, returning early when ctx is cancelled.
+func (conn *Connector) sleep(ctx context.Context, d time.Duration) {
+    timer := time.NewTimer(d)
+    defer timer.Stop()
+
+    select {
+    case <-timer.C:
+    case <-ctx.Done():
+    }
+}
+
+// claim hands the next unit of work to a Pool worker.
+func (conn *Connector) claim(ctx context.Context, claimed *int, start time.Time) (int, bool) {
+    conn.lock.Lock()
+    defer conn.lock.Unlock()
+
+    if !conn.more(ctx, *claimed, start) {
+        return 0, false
+    }
+
+    i := *claimed
+    *claimed++
+    return i, true
+}
+
+// work runs unit i of a run as client, a single request or, with
+// Sessions, a whole session. Sessions are their own users. Requests are
+// scheduled for intended, unless zero, sessions are not.
+func (conn *Connector) work(ctx context.Context, client *http.Client, i int, intended time.Time) {
+    if len(conn.Sessions) > 0 {
+        conn.session(ctx, conn.Sessions[i%len(conn.Sessions)])
+        return
+    }
+
+    conn.request(ctx, client, conn.next(), intended)
+}
+
+// session runs s, adding its result once it completes, fails or is
+// cut short by ctx.
+func (conn *Connector) session(ctx context.Context, s Session) {
+    client := conn.user()
+    start := time.Now()
+    result := results.SessionResult{}
+
+    defer func() {
+        result.Took = ms(time.Since(start))
+
+        conn.lock.Lock()
+        conn.Results.AddSession(result)
+        conn.lock.Unlock()
+    }()
+
+    for i, req := range s.Requests {
+        if i > 0 && s.Requests[i-1].Think > 0 {
+            conn.sleep(ctx, s.Requests[i-1].Think)
+        }
+
+        if ctx.Err() != nil {
+            return
+        }
+
+        ok := conn.burst(ctx, client, req)
+        result.Requests += 1 + len(req.Burst)
+
+        if ctx.Err() != nil {
+            return
+        }
+
+        if !ok {
+            result.Failed = true
+            return
+        }
+    }
+
+    result.Completed = true
+}
+
+// burst issues req, then its Burst concurrently, reporting whether all
+// succeeded.
+func (conn *Connector) burst(ctx context.Context, client *http.Client, req SessionRequest) bool {
+    if conn.request(ctx, client, conn.target(req.Target), time.Time{}).Error != nil {
+        return false
+    }
+
+    ok := true
+    lock := sync.Mutex{}
+    waiter := sync.WaitGroup{}
+
+    for _, target := range req.Burst {
+        waiter.Add(1)
+        go func(target Target) {
+            defer waiter.Done()
+
+            if conn.request(ctx, client, conn.target(target), time.Time{}).Error != nil {
+                lock.Lock()
+                ok = false
+                lock.Unlock()
+            }
+        }(target)
+    }
+
+    waiter.Wait()
+    return ok
+}
+
+// request issues a request for target as client, adding its result.
+// Intended is when it was scheduled, zero if not.
+func (conn *Connector) request(ctx context.Context, client *http.Client, target Target, intended time.Time) results.Result {
+    conn.lock.Lock()
+    index := conn.requested
+    conn.requested++
+    conn.lock.Unlock()
+
+    result := conn.connect(ctx, client, target)
+    result.Index = index
+    result.Intended = intended
+    conn.add(result)
+    return result
+}
+
+// begin starts a run, clearing Results of any previous run.
+func (conn *Connector) begin() time.Time {
+    conn.lock.Lock()
+    conn.requested = 0
+    conn.Results.Reset()
+
+    // set to -1 so that it gets the first connection time
+    conn.Results.ConnectTime = -1
+    conn.lock.Unlock()
+
+    return time.Now()
+}
+
+func (conn *Connector) add(result results.Result) {
+    conn.lock.Lock()
+    conn.Results.Add(result)
+
+    // Write errors are sticky, and surface from Log.Flush.
+    if conn.Log != nil {
+        conn.Log.Write(results.NewRecord(result))
+    }
+    conn.lock.Unlock()
+}
+
+func (conn *Connector) finalize(ctx context.Context, start time.Time) {
     if conn.Verbose {
-        fmt.Println(" > finalizing...\n")
+        fmt.Fprint(conn.messages(), " > finalizing...\n\n")
     }
 
+    conn.lock.Lock()
+    issued := conn.requested
+    conn.lock.Unlock()
+
     // Some results data can only be populated if run via Connector.
-    conn.Results.Requested = conn.NumConns
+    conn.Results.Requested = issued
+    conn.Results.Interrupted = ctx.Err() != nil
     conn.Results.TotalTime = float64(time.Since(start))/float64(time.Second)
-    conn.Results.ConnPerSec = float64(conn.NumConns)/conn.Results.TotalTime
+    conn.Results.ConnPerSec = float64(conn.Results.Connections)/conn.Results.TotalTime
+    conn.Results.ReqPerSec = float64(issued)/conn.Results.TotalTime
+
+    if conn.Results.Sessions > 0 {
+        conn.Results.SessionPerSec = float64(conn.Results.SessionsCompleted)/conn.Results.TotalTime
+    }
+
+    if conn.Log != nil {
+        conn.Log.Flush()
+    }
 
     // Finalize results.
     conn.Results.Finalize()
@@ -1,11 +1,32 @@
 package connector
 
 import (
+    "bufio"
+    "bytes"
+    "compress/gzip"
+    "context"
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/sha256"
+    "crypto/tls"
+    "crypto/x509"
+    "crypto/x509/pkix"
+    "errors"
     "fmt"
+    "io/ioutil"
+    "math/big"
+    "strings"
+    "sync"
     "testing"
     "time"
+    "net"
     "net/http"
+    "net/http/httptest"
+    "net/url"
+    "regexp"
     "github.com/jmervine/GoT"
+    "github.com/jmervine/goperf/results"
 )
 
 var StubServerRunning = false
@@ -19,11 +40,33 @@ func TestNew(T *testing.T) {
     Go(T).AssertEqual(c.Verbose, false)
     Go(T).AssertEqual(c.Rate, 0)
     Go(T).AssertEqual(c.Results.ConnectTime, -1)
-    Go(T).AssertLength(c.Results.Took, 10)
+
+    // Results grow as they are added, rather than being pre-sized.
+    Go(T).AssertLength(c.Results.Took, 0)
+    Go(T).AssertEqual(cap(c.Results.Took), 10)
+}
+
+func TestNewErrors(T *testing.T) {
+    _, err := New("http://%zz", 1)
+    Go(T).Refute(err == nil)
+
+    _, err = New("http://", 1)
+    uerr, ok := err.(*url.Error)
+    Go(T).Assert(ok)
+    Go(T).AssertEqual(uerr.Err, ErrMissingHost)
+
+    _, err = New("ftp://localhost", 1)
+    uerr, ok = err.(*url.Error)
+    Go(T).Assert(ok)
+    Go(T).AssertEqual(uerr.Err, ErrUnsupportedScheme)
+
+    c, err := New("http://localhost:9877", 1)
+    Go(T).Assert(err == nil)
+    Go(T).AssertEqual(c.Path, "http://localhost:9877")
 }
 
 func TestSeries(T *testing.T) {
-    go stubServer()
+    stubServer()
 
     c := Connector{}.New("http://localhost:9877", 10)
     c.Series()
@@ -36,7 +79,7 @@ func TestSeries(T *testing.T) {
 }
 
 func TestParallel(T *testing.T) {
-    go stubServer()
+    stubServer()
 
     c := Connector{}.New("http://localhost:9877", 10)
     c.Parallel()
@@ -49,8 +92,469 @@ func TestParallel(T *testing.T) {
     Go(T).RefuteEqual(c.Results.TookMed, 0)
 }
 
+func TestParallelSchedule(T *testing.T) {
+    stubServer()
+
+    c := Connector{}.New("http://localhost:9877", 5)
+    c.Rate = 50
+    c.Parallel()
+
+    Go(T).AssertEqual(c.Results.Response.Count, 5)
+    Go(T).Assert(c.Results.Response.Max >= c.Results.TookMax)
+
+    // Unscheduled runs have no corrected times.
+    c = Connector{}.New("http://localhost:9877", 5)
+    c.Series()
+
+    Go(T).AssertEqual(c.Results.Response.Count, 0)
+
+    // Arrival replaces Rate, here two bursts of 3, 150ms apart.
+    c = Connector{}.New("http://localhost:9877", 6)
+    c.Arrival = &Burst{Rate: 20, Size: 3}
+
+    begin := time.Now()
+    c.Run()
+
+    Go(T).AssertEqual(c.Results.Replies, 6)
+    Go(T).AssertEqual(c.Results.Response.Count, 6)
+    Go(T).Assert(time.Since(begin) >= 150*time.Millisecond)
+}
+
+func TestDuration(T *testing.T) {
+    stubServer()
+
+    c := Connector{}.New("http://localhost:9877", 0)
+    c.Duration = 200 * time.Millisecond
+    c.Series()
+
+    Go(T).RefuteEqual(c.Results.Requested, 0)
+    Go(T).AssertLength(c.Results.Took, c.Results.Requested)
+    Go(T).AssertEqual(c.Results.Code2xx, c.Results.Requested)
+
+    c = Connector{}.New("http://localhost:9877", 0)
+    c.Duration = 500 * time.Millisecond
+    c.Rate = 10
+    c.Parallel()
+
+    Go(T).Assert(c.Results.Requested >= 4 && c.Results.Requested <= 6)
+    Go(T).AssertLength(c.Results.Took, c.Results.Requested)
+    Go(T).AssertEqual(c.Results.Code2xx, c.Results.Requested)
+
+    // Without Rate, a timed Parallel run is serialized rather than
+    // unbounded.
+    c = Connector{}.New("http://localhost:9877", 0)
+    c.Duration = 200 * time.Millisecond
+    c.Parallel()
+
+    Go(T).AssertEqual(c.Results.Concurrency, 1)
+    Go(T).AssertEqual(c.Results.Connections, c.Results.Requested)
+    Go(T).AssertEqual(c.Results.Code2xx, c.Results.Requested)
+
+    // NumConns still caps a timed run.
+    c = Connector{}.New("http://localhost:9877", 3)
+    c.Duration = time.Minute
+    c.Series()
+
+    Go(T).AssertEqual(c.Results.Requested, 3)
+}
+
+func TestPool(T *testing.T) {
+    var lock sync.Mutex
+    var inflight, peak int
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        lock.Lock()
+        inflight++
+        if inflight > peak {
+            peak = inflight
+        }
+        lock.Unlock()
+
+        time.Sleep(20 * time.Millisecond)
+
+        lock.Lock()
+        inflight--
+        lock.Unlock()
+    }))
+    defer server.Close()
+
+    c := Connector{}.New(server.URL, 12)
+    c.Concurrency = 3
+    c.Run()
+
+    Go(T).AssertEqual(c.Results.Requested, 12)
+    Go(T).AssertEqual(c.Results.Concurrency, 3)
+    Go(T).AssertEqual(c.Results.Code2xx, 12)
+    Go(T).AssertEqual(peak, 3)
+}
+
+func TestKeepAlive(T *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprintln(w, "hello web")
+    }))
+    defer server.Close()
+
+    c := Connector{}.New(server.URL, 10)
+    c.Series()
+
+    Go(T).AssertEqual(c.Results.Connections, 10)
+
+    c = Connector{}.New(server.URL, 10)
+    c.KeepAlive = true
+    c.Series()
+
+    Go(T).AssertEqual(c.Results.Connections, 1)
+
+    c = Connector{}.New(server.URL, 20)
+    c.KeepAlive = true
+    c.ConnsPerHost = 2
+    c.Concurrency = 5
+    c.Pool()
+
+    Go(T).Assert(c.Results.Connections <= 2)
+    Go(T).AssertEqual(c.Results.Code2xx, 20)
+}
+
+func TestLog(T *testing.T) {
+    stubServer()
+
+    var buf bytes.Buffer
+    c := Connector{}.New("http://localhost:9877", 5)
+    c.Log = results.NewCSVWriter(&buf)
+    c.Rate = 50
+    c.Parallel()
+
+    lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+    Go(T).AssertLength(lines, 6)
+    Go(T).Assert(strings.HasPrefix(lines[0], "index,target,start,took"))
+}
+
+func TestMessages(T *testing.T) {
+    stubServer()
+
+    var buf bytes.Buffer
+    c := Connector{}.New("http://localhost:9877", 2)
+    c.Verbose = true
+    c.Messages = &buf
+    c.Series()
+
+    Go(T).AssertEqual(strings.Count(buf.String(), " > Responded in"), 2)
+    Go(T).Assert(strings.Contains(buf.String(), " > finalizing..."))
+}
+
+func TestContext(T *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        select {
+        case <-time.After(100 * time.Millisecond):
+        case <-r.Context().Done():
+        }
+    }))
+    defer server.Close()
+
+    ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+    defer cancel()
+
+    c := Connector{}.New(server.URL, 100)
+    c.Concurrency = 2
+    c.RunContext(ctx)
+
+    Go(T).Assert(c.Results.Interrupted)
+    Go(T).Assert(c.Results.Requested < 100)
+    Go(T).AssertEqual(c.Results.ErrorsCanceled, 2)
+    Go(T).AssertEqual(c.Results.Replies, c.Results.Requested-2)
+    Go(T).AssertEqual(c.Results.Code2xx, c.Results.Requested-2)
+    Go(T).AssertLength(c.Results.Took, c.Results.Replies)
+
+    ctx, cancel = context.WithCancel(context.Background())
+    c = Connector{}.New(server.URL, 100)
+    c.Rate = 10
+    go func() {
+        time.Sleep(250 * time.Millisecond)
+        cancel()
+    }()
+    c.ParallelContext(ctx)
+
+    Go(T).Assert(c.Results.Interrupted)
+    Go(T).Assert(c.Results.Requested <= 4)
+    Go(T).AssertEqual(c.Results.Replies, c.Results.Requested-c.Results.ErrorsCanceled)
+
+    // Canceled requests are kept out of latency stats.
+    slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        select {
+        case <-time.After(500 * time.Millisecond):
+        case <-r.Context().Done():
+        }
+    }))
+    defer slow.Close()
+
+    ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+    defer cancel()
+
+    c = Connector{}.New(slow.URL, 5)
+    c.ParallelContext(ctx)
+
+    Go(T).AssertEqual(c.Results.Requested, 5)
+    Go(T).AssertEqual(c.Results.ErrorsCanceled, 5)
+    Go(T).AssertEqual(c.Results.Replies, 0)
+    Go(T).AssertEqual(c.Results.TookMax, 0.0)
+    Go(T).AssertEqual(c.Results.Latency.Count(), int64(0))
+    Go(T).AssertEqual(c.Results.Histogram.Total, int64(0))
+
+    c = Connector{}.New(server.URL, 10)
+    c.Series()
+    Go(T).Refute(c.Results.Interrupted)
+}
+
+func TestTimeout(T *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        select {
+        case <-time.After(300 * time.Millisecond):
+        case <-r.Context().Done():
+        }
+    }))
+    defer server.Close()
+
+    c := Connector{}.New(server.URL, 3)
+    c.HeaderTimeout = 50 * time.Millisecond
+    c.Series()
+
+    Go(T).AssertEqual(c.Results.ErrorsTotal, 3)
+    Go(T).AssertEqual(c.Results.ErrorsClientTimeout, 3)
+    Go(T).Assert(c.Results.TookMax < 300)
+
+    c = Connector{}.New(server.URL, 3)
+    c.Timeout = 50 * time.Millisecond
+    c.Concurrency = 3
+    c.Pool()
+
+    Go(T).AssertEqual(c.Results.ErrorsClientTimeout, 3)
+    Go(T).AssertEqual(c.Results.ErrorsOther, 0)
+
+    // ConnectTimeout covers the TLS handshake, here never answered.
+    ln, _ := net.Listen("tcp", "127.0.0.1:0")
+    defer ln.Close()
+    go func() {
+        for {
+            silent, err := ln.Accept()
+            if err != nil {
+                return
+            }
+            defer silent.Close()
+        }
+    }()
+
+    c = Connector{}.New("https://"+ln.Addr().String(), 1)
+    c.ConnectTimeout = 50 * time.Millisecond
+    c.Series()
+
+    Go(T).AssertEqual(c.Results.ErrorsConnTimeout, 1)
+    Go(T).AssertEqual(c.Results.ErrorsClientTimeout, 0)
+}
+
+func TestTargets(T *testing.T) {
+    var lock sync.Mutex
+    hits := map[string]int{}
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        lock.Lock()
+        hits[r.Method+" "+r.URL.Path]++
+        lock.Unlock()
+    }))
+    defer server.Close()
+
+    var log bytes.Buffer
+    c := Connector{}.New(server.URL, 6)
+    c.Log = results.NewJSONLWriter(&log)
+    c.Targets = []Target{
+        {Method: "GET", Path: "/a", Weight: 1},
+        {Method: "GET", Path: server.URL + "/b", Weight: 1},
+        {Method: "POST", Path: "/c", Body: []byte("data"), Weight: 1},
+    }
+    c.Series()
+
+    // Log records name their target.
+    Go(T).AssertEqual(strings.Count(log.String(), `"target":"POST `+server.URL+`/c"`), 2)
+
+    Go(T).AssertEqual(hits["GET /a"], 2)
+    Go(T).AssertEqual(hits["GET /b"], 2)
+    Go(T).AssertEqual(hits["POST /c"], 2)
+
+    Go(T).AssertLength(c.Results.Targets, 3)
+    a := c.Results.Targets["GET "+server.URL+"/a"]
+    Go(T).AssertEqual(a.Replies, 2)
+    Go(T).AssertEqual(a.Code2xx, 2)
+    Go(T).RefuteEqual(a.TookAvg, 0)
+
+    hits = map[string]int{}
+    c = Connector{}.New(server.URL, 200)
+    c.Selection = Weighted
+    c.Targets = []Target{
+        {Method: "GET", Path: "/light", Weight: 1},
+        {Method: "GET", Path: "/heavy", Weight: 9},
+    }
+    c.Concurrency = 4
+    c.Pool()
+
+    Go(T).AssertEqual(hits["GET /light"]+hits["GET /heavy"], 200)
+    Go(T).Assert(hits["GET /heavy"] > hits["GET /light"])
+
+    hits = map[string]int{}
+    c = Connector{}.New(server.URL, 50)
+    c.Selection = Random
+    c.Targets = []Target{
+        {Method: "GET", Path: "/x", Weight: 1},
+        {Method: "GET", Path: "/y", Weight: 1},
+    }
+    c.Series()
+
+    Go(T).AssertEqual(hits["GET /x"]+hits["GET /y"], 50)
+    Go(T).AssertLength(c.Results.Targets, 2)
+}
+
+func TestSessions(T *testing.T) {
+    var lock sync.Mutex
+    hits := map[string]int{}
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        lock.Lock()
+        hits[r.Method+" "+r.URL.Path]++
+        lock.Unlock()
+    }))
+    defer server.Close()
+
+    c := Connector{}.New(server.URL, 4)
+    c.Rate = 100
+    c.Sessions = []Session{
+        {Requests: []SessionRequest{
+            {
+                Target: Target{Method: "GET", Path: "/page"},
+                Burst: []Target{
+                    {Method: "GET", Path: "/style.css"},
+                    {Method: "GET", Path: "/logo.png"},
+                },
+                Think: 10 * time.Millisecond,
+            },
+            {Target: Target{Method: "POST", Path: "/login", Body: []byte("user")}},
+        }},
+    }
+    c.Run()
+
+    Go(T).AssertEqual(hits["GET /page"], 4)
+    Go(T).AssertEqual(hits["GET /style.css"], 4)
+    Go(T).AssertEqual(hits["POST /login"], 4)
+
+    Go(T).AssertEqual(c.Results.Requested, 16)
+    Go(T).AssertEqual(c.Results.Replies, 16)
+    Go(T).AssertEqual(c.Results.Sessions, 4)
+    Go(T).AssertEqual(c.Results.SessionsCompleted, 4)
+    Go(T).AssertEqual(c.Results.SessionsFailed, 0)
+    Go(T).AssertEqual(c.Results.Session.Count, 4)
+    Go(T).Assert(c.Results.Session.Min >= 10)
+    Go(T).RefuteEqual(c.Results.SessionPerSec, 0)
+    Go(T).AssertLength(c.Results.Targets, 4)
+}
+
+func TestSessionsFailed(T *testing.T) {
+    c := Connector{}.New("http://localhost:9898/", 2)
+    c.Concurrency = 2
+    c.Sessions = []Session{
+        {Requests: []SessionRequest{
+            {Target: Target{Method: "GET", Path: "http://127.0.0.1:1/refused"}},
+            {Target: Target{Method: "GET", Path: "/never"}},
+        }},
+    }
+    c.Run()
+
+    // The session stops at the failed request.
+    Go(T).AssertEqual(c.Results.Requested, 2)
+    Go(T).AssertEqual(c.Results.Sessions, 2)
+    Go(T).AssertEqual(c.Results.SessionsCompleted, 0)
+    Go(T).AssertEqual(c.Results.SessionsFailed, 2)
+    Go(T).AssertEqual(c.Results.Session.Count, 0)
+}
+
+func TestCookies(T *testing.T) {
+    var lock sync.Mutex
+    logins := 0
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path == "/login" {
+            lock.Lock()
+            logins++
+            lock.Unlock()
+
+            http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret", Path: "/"})
+            return
+        }
+
+        if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "secret" {
+            w.WriteHeader(http.StatusUnauthorized)
+        }
+    }))
+    defer server.Close()
+
+    sessions := []Session{
+        {Requests: []SessionRequest{
+            {Target: Target{Method: "POST", Path: "/login"}},
+            {
+                Target: Target{Method: "GET", Path: "/dashboard"},
+                Burst:  []Target{{Method: "GET", Path: "/dashboard/stats"}},
+            },
+        }},
+    }
+
+    c := Connector{}.New(server.URL, 3)
+    c.Concurrency = 2
+    c.Sessions = sessions
+    c.Run()
+
+    Go(T).AssertEqual(c.Results.Code2xx, 3)
+    Go(T).AssertEqual(c.Results.Code4xx, 6)
+
+    c = Connector{}.New(server.URL, 3)
+    c.Concurrency = 2
+    c.Sessions = sessions
+    c.Cookies = true
+    c.Run()
+
+    // Each session is a new user, logging in once.
+    Go(T).AssertEqual(c.Results.Code2xx, 9)
+    Go(T).AssertEqual(logins, 6)
+
+    // Series is a single user, so cookies carry across requests.
+    c = Connector{}.New(server.URL, 3)
+    c.Targets = []Target{
+        {Method: "POST", Path: "/login", Weight: 1},
+        {Method: "GET", Path: "/dashboard", Weight: 1},
+    }
+    c.Cookies = true
+    c.Series()
+
+    Go(T).AssertEqual(c.Results.Code2xx, 3)
+}
+
+func TestCheck(T *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path == "/broken" {
+            fmt.Fprint(w, "Something went wrong")
+            return
+        }
+        fmt.Fprint(w, "Welcome")
+    }))
+    defer server.Close()
+
+    c := Connector{}.New(server.URL, 4)
+    c.Targets = []Target{
+        {Method: "GET", Path: "/", Weight: 1},
+        {Method: "GET", Path: "/broken", Weight: 1},
+    }
+    c.Check = &Check{Status: []int{200}, Body: "Welcome"}
+    c.Series()
+
+    Go(T).AssertEqual(c.Results.Code2xx, 4)
+    Go(T).AssertEqual(c.Results.ErrorsTotal, 2)
+    Go(T).AssertEqual(c.Results.ErrorsCheckFailed, 2)
+    Go(T).AssertEqual(c.Results.Targets["GET "+server.URL+"/broken"].ErrorsCheckFailed, 2)
+}
+
 func TestRun(T *testing.T) {
-    go stubServer()
+    stubServer()
 
     c := Connector{}.New("http://localhost:9877", 10)
     c.Run()
@@ -80,10 +584,18 @@ func TestRun(T *testing.T) {
         Go(T).AssertEqual(c.Results.Code[i], 200)
     }
     Go(T).RefuteEqual(c.Results.ConnectTime, -1)
+
+    // Each run reports only its own requests and connections.
+    Go(T).AssertEqual(c.Results.Requested, 10)
+    Go(T).AssertEqual(c.Results.Replies, 10)
+    Go(T).AssertEqual(c.Results.Connections, 10)
+    Go(T).AssertEqual(c.Results.ConnPerSec, c.Results.ReqPerSec)
+    Go(T).AssertEqual(c.Results.Latency.Count(), int64(10))
+    Go(T).AssertEqual(c.Results.Histogram.Total, int64(10))
 }
 
 func TestConnect(T *testing.T) {
-    go stubServer()
+    stubServer()
 
     c := Connector{}.New("http://localhost:9877", 10)
     r := c.Connect()
@@ -95,12 +607,294 @@ func TestConnect(T *testing.T) {
     Go(T).RefuteEqual(c.Results.ConnectTime, -1)
 }
 
+func TestConnectTiming(T *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        time.Sleep(10 * time.Millisecond)
+        w.(http.Flusher).Flush()
+        time.Sleep(10 * time.Millisecond)
+        fmt.Fprintln(w, "hello web")
+    }))
+    defer server.Close()
+
+    c := Connector{}.New(server.URL, 1)
+    r := c.Connect()
+
+    Go(T).Assert(r.Timing.Connect > 0)
+    Go(T).Assert(r.Timing.TTFB >= 10)
+    Go(T).Assert(r.Timing.Transfer >= 10)
+    Go(T).Assert(r.Took >= r.Timing.TTFB+r.Timing.Transfer)
+    Go(T).AssertEqual(r.Timing.TLS, 0)
+
+    c.Series()
+    Go(T).AssertEqual(c.Results.Connect.Count, 1)
+    Go(T).AssertEqual(c.Results.TTFB.Count, 1)
+    Go(T).Assert(c.Results.Transfer.Min >= 10)
+}
+
+func TestConnectSizes(T *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path == "/chunked" {
+            w.(http.Flusher).Flush()
+            fmt.Fprint(w, "hello web")
+            return
+        }
+        fmt.Fprint(w, strings.Repeat("x", 100))
+    }))
+    defer server.Close()
+
+    c := Connector{}.New(server.URL+"/chunked", 1)
+    r := c.Connect()
+
+    Go(T).AssertEqual(r.ContentLength, int64(9))
+    Go(T).Assert(r.HeaderLength > int64(len("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n")))
+    Go(T).AssertEqual(r.TotalLength, r.HeaderLength+r.ContentLength)
+
+    c = Connector{}.New(server.URL, 4)
+    c.Targets = []Target{
+        {Method: "GET", Path: "/chunked", Weight: 1},
+        {Method: "GET", Path: "/", Weight: 1},
+    }
+    c.Series()
+
+    Go(T).AssertEqual(c.Results.ContentLength, int64(218))
+    Go(T).AssertEqual(c.Results.ContentSize.Min, int64(9))
+    Go(T).AssertEqual(c.Results.ContentSize.Max, int64(100))
+    Go(T).AssertEqual(c.Results.ContentSize.Avg, 54.5)
+    Go(T).Assert(c.Results.KBPerSec > 0)
+    Go(T).AssertEqual(c.Results.Targets["GET "+server.URL+"/"].ContentLength, int64(200))
+    Go(T).AssertEqual(c.Results.HeaderEstimated, 0)
+
+    // Header bytes are counted as sent, however the server spells them.
+    header := "HTTP/1.1 200 OK\r\ncontent-length:   5\r\nX-Pad:  " + strings.Repeat("p", 100) + "\r\n\r\n"
+    ln, _ := net.Listen("tcp", "127.0.0.1:0")
+    defer ln.Close()
+    go func() {
+        for {
+            raw, err := ln.Accept()
+            if err != nil {
+                return
+            }
+            go func() {
+                defer raw.Close()
+                http.ReadRequest(bufio.NewReader(raw))
+                fmt.Fprint(raw, header+"hello")
+            }()
+        }
+    }()
+
+    c = Connector{}.New("http://"+ln.Addr().String(), 1)
+    r = c.Connect()
+
+    Go(T).AssertEqual(r.HeaderLength, int64(len(header)))
+    Go(T).AssertEqual(r.TotalLength, int64(len(header)+5))
+    Go(T).Refute(r.HeaderEstimated)
+}
+
+func TestConnectCompression(T *testing.T) {
+    body := strings.Repeat("x", 100000)
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+            fmt.Fprint(w, body)
+            return
+        }
+
+        w.Header().Set("Content-Encoding", "gzip")
+        zw := gzip.NewWriter(w)
+        fmt.Fprint(zw, body)
+        zw.Close()
+    }))
+    defer server.Close()
+
+    // Compression is not asked for unless set.
+    c := Connector{}.New(server.URL, 1)
+    r := c.Connect()
+    Go(T).AssertEqual(r.ContentLength, int64(len(body)))
+
+    // Compressed bodies are counted as sent.
+    c.Header = http.Header{"Accept-Encoding": {"gzip"}}
+    r = c.Connect()
+    Go(T).Assert(r.ContentLength < 1000)
+}
+
+func TestConsume(T *testing.T) {
+    body := strings.Repeat("x", 1<<20)
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprint(w, body)
+    }))
+    defer server.Close()
+
+    c := Connector{}.New(server.URL, 1)
+    r := c.Connect()
+    Go(T).AssertEqual(r.ContentLength, int64(len(body)))
+    Go(T).AssertEqual(r.Digest, "")
+
+    c.Consume = Hash
+    r = c.Connect()
+    Go(T).AssertEqual(r.ContentLength, int64(len(body)))
+    Go(T).AssertEqual(r.Digest, fmt.Sprintf("%x", sha256.Sum256([]byte(body))))
+
+    // Checks see only what is kept, but sizes are of the full body.
+    c.Consume = Keep
+    c.KeepBytes = 4
+    c.Check = &Check{Pattern: regexp.MustCompile(`^x{4}$`), MaxSize: 1 << 20}
+    r = c.Connect()
+    Go(T).Assert(r.Error == nil)
+    Go(T).AssertEqual(r.ContentLength, int64(len(body)))
+
+    c.Check.MaxSize = 1024
+    r = c.Connect()
+    Go(T).Assert(errors.Is(r.Error, results.ErrCheckFailed))
+
+    // Bodies are kept by default for body checks.
+    c = Connector{}.New(server.URL, 1)
+    c.Check = &Check{Body: "xxx"}
+    Go(T).Assert(c.Connect().Error == nil)
+}
+
+func TestTLS(T *testing.T) {
+    var serverName string
+    var clientCerts int
+    server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        serverName = r.TLS.ServerName
+        clientCerts = len(r.TLS.PeerCertificates)
+        fmt.Fprint(w, "secure")
+    }))
+    server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+    server.StartTLS()
+    defer server.Close()
+
+    roots := x509.NewCertPool()
+    roots.AddCert(server.Certificate())
+
+    // Unknown CA, and no client certificate.
+    c := Connector{}.New(server.URL, 1)
+    Go(T).Refute(c.Connect().Error == nil)
+
+    c = Connector{}.New(server.URL, 3)
+    c.TLS = &tls.Config{
+        RootCAs:      roots,
+        Certificates: []tls.Certificate{clientCert()},
+        ServerName:   "example.com",
+    }
+    c.Series()
+
+    Go(T).AssertEqual(c.Results.Code2xx, 3)
+    Go(T).AssertEqual(serverName, "example.com")
+    Go(T).AssertEqual(clientCerts, 1)
+    Go(T).AssertEqual(c.Results.TLSVersions["TLS 1.3"], 3)
+    Go(T).AssertEqual(c.Results.TLSCiphers["TLS_AES_128_GCM_SHA256"], 3)
+
+    // New connections resume via the session cache.
+    Go(T).Assert(c.Results.TLSResumed > 0)
+
+    // Version and cipher pinning.
+    c = Connector{}.New(server.URL, 1)
+    c.TLS = &tls.Config{
+        RootCAs:      roots,
+        Certificates: []tls.Certificate{clientCert()},
+        MaxVersion:   tls.VersionTLS12,
+        CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384},
+    }
+    r := c.Connect()
+
+    Go(T).AssertEqual(r.TLSVersion, "TLS 1.2")
+    Go(T).AssertEqual(r.TLSCipher, "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384")
+}
+
+func TestProtocol(T *testing.T) {
+    handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        time.Sleep(5 * time.Millisecond)
+        fmt.Fprint(w, r.Proto)
+    })
+
+    server := httptest.NewUnstartedServer(handler)
+    server.EnableHTTP2 = true
+    server.StartTLS()
+    defer server.Close()
+
+    roots := x509.NewCertPool()
+    roots.AddCert(server.Certificate())
+
+    c := Connector{}.New(server.URL, 40)
+    c.TLS = &tls.Config{RootCAs: roots}
+    c.Protocol = HTTP2
+    c.ConnsPerHost = 2
+    c.Concurrency = 10
+    c.Pool()
+
+    Go(T).AssertEqual(c.Results.Protocols["HTTP/2.0"], 40)
+    Go(T).AssertEqual(c.Results.HeaderEstimated, 40)
+    Go(T).AssertEqual(c.Results.Connections, 2)
+    Go(T).AssertEqual(c.Results.StreamsPerConn.Count, 2)
+    Go(T).AssertEqual(c.Results.StreamsPerConn.Avg, 20.0)
+
+    // h2c, over a single connection by default.
+    cleartext := httptest.NewUnstartedServer(handler)
+    cleartext.Config.Protocols = &http.Protocols{}
+    cleartext.Config.Protocols.SetHTTP1(true)
+    cleartext.Config.Protocols.SetUnencryptedHTTP2(true)
+    cleartext.Start()
+    defer cleartext.Close()
+
+    c = Connector{}.New(cleartext.URL, 20)
+    c.Protocol = H2C
+    c.Parallel()
+
+    Go(T).AssertEqual(c.Results.Protocols["HTTP/2.0"], 20)
+    Go(T).AssertEqual(c.Results.Connections, 1)
+    Go(T).AssertEqual(c.Results.StreamsPerConn.Max, int64(20))
+
+    // HTTP/1.1 connections are one per request, unless kept alive.
+    c = Connector{}.New(cleartext.URL, 4)
+    c.Protocol = HTTP1
+    c.Series()
+
+    Go(T).AssertEqual(c.Results.Protocols["HTTP/1.1"], 4)
+    Go(T).AssertEqual(c.Results.StreamsPerConn.Count, 0)
+
+    c = Connector{}.New(cleartext.URL, 4)
+    c.KeepAlive = true
+    c.Series()
+
+    Go(T).AssertEqual(c.Results.StreamsPerConn.Max, int64(4))
+
+    // Replies falling back to HTTP/1.1 are errors.
+    c = Connector{}.New(cleartext.URL, 1)
+    c.Protocol = HTTP2
+    r := c.Connect()
+
+    Go(T).AssertEqual(r.Proto, "HTTP/1.1")
+    Go(T).Assert(errors.Is(r.Error, ErrUnexpectedProtocol))
+}
+
+func TestConnectRequest(T *testing.T) {
+    var method, header, body string
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        content, _ := ioutil.ReadAll(r.Body)
+        method, header, body = r.Method, r.Header.Get("X-Test"), string(content)
+        w.WriteHeader(201)
+    }))
+    defer server.Close()
+
+    c := Connector{}.New(server.URL, 1)
+    c.Method = "PUT"
+    c.Header.Set("X-Test", "goperf")
+    c.Body = []byte(`{"hello":"web"}`)
+
+    r := c.Connect()
+
+    Go(T).AssertEqual(r.Code, 201)
+    Go(T).AssertEqual(method, "PUT")
+    Go(T).AssertEqual(header, "goperf")
+    Go(T).AssertEqual(body, `{"hello":"web"}`)
+}
+
 /***
  * Examples
  ******************************/
 
 func ExampleConnector_New() {
-    go stubServer()
+    stubServer()
 
     c := Connector{}.New("http://localhost:9877", 10)
 
@@ -125,20 +919,43 @@ func ExampleConnector_New() {
 /***
  * Helpers
  ******************************/
+// clientCert generates a self-signed client certificate.
+func clientCert() tls.Certificate {
+    key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    template := &x509.Certificate{
+        SerialNumber: big.NewInt(1),
+        Subject:      pkix.Name{CommonName: "goperf"},
+        NotBefore:    time.Now().Add(-time.Hour),
+        NotAfter:     time.Now().Add(time.Hour),
+        ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+    }
+
+    der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+    if err != nil {
+        panic(err)
+    }
+    return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
 func stubServer() {
     if StubServerRunning {
         return
     }
 
     StubServerRunning = true
-    defer func() { StubServerRunning = false }()
 
     // Starting a stub server on :9877 to handle incoming requests
-    // for example.
+    // for example. The listener is opened before returning so that
+    // requests made right after never race the server start.
     http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
         time.Sleep(5 * time.Millisecond)
         fmt.Fprintln(w, "hello web")
     })
-    http.ListenAndServe(":9877", nil)
+
+    listener, err := net.Listen("tcp", ":9877")
+    if err != nil {
+        panic(err)
+    }
+    go http.Serve(listener, nil)
 }
 
@@ -0,0 +1,55 @@
+package connector
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "io"
+    "io/ioutil"
+)
+
+// Consumers for response bodies, see Connector.Consume.
+const (
+    Discard = "discard"
+    Hash    = "hash"
+    Keep    = "keep"
+)
+
+// consume streams body to EOF, returning the bytes kept for Check, the
+// body size and, when hashing, its hex SHA-256 digest.
+func (conn *Connector) consume(body io.Reader) ([]byte, int64, string, error) {
+    c := &counter{Reader: body}
+
+    var kept []byte
+    var digest string
+    var err error
+
+    switch conn.consumer() {
+    case Hash:
+        h := sha256.New()
+        _, err = io.Copy(h, c)
+        digest = hex.EncodeToString(h.Sum(nil))
+    case Keep:
+        if conn.KeepBytes > 0 {
+            kept, err = ioutil.ReadAll(io.LimitReader(c, conn.KeepBytes))
+        } else {
+            kept, err = ioutil.ReadAll(c)
+        }
+
+        if err == nil {
+            _, err = io.Copy(ioutil.Discard, c)
+        }
+    default:
+        _, err = io.Copy(ioutil.Discard, c)
+    }
+
+    return kept, c.n, digest, err
+}
+
+// consumer returns the Consume in effect, keeping bodies by default
+// when Check has body rules.
+func (conn *Connector) consumer() string {
+    if conn.Consume == "" && conn.Check != nil && conn.Check.needsBody() {
+        return Keep
+    }
+    return conn.Consume
+}
@@ -0,0 +1,59 @@
+package connector
+
+import (
+    "crypto/tls"
+    "net"
+    "net/http"
+    "sync/atomic"
+)
+
+// Protocols for Connector.Protocol.
+const (
+    HTTP1 = "http1.1"
+    HTTP2 = "h2"
+    H2C   = "h2c"
+)
+
+// multiplexer spreads requests over transports in turn, each keeping a
+// single HTTP/2 connection per host while under the server's stream
+// limit, so a run uses a fixed number of connections.
+type multiplexer struct {
+    transports []*http.Transport
+    next       uint64
+}
+
+func (m *multiplexer) RoundTrip(req *http.Request) (*http.Response, error) {
+    i := atomic.AddUint64(&m.next, 1)
+    return m.transports[i%uint64(len(m.transports))].RoundTrip(req)
+}
+
+// numbered is a dialed connection, numbered from 1 in dial order,
+// counting bytes read.
+type numbered struct {
+    net.Conn
+    id   int
+    read int64
+}
+
+func (n *numbered) Read(p []byte) (int, error) {
+    c, err := n.Conn.Read(p)
+    atomic.AddInt64(&n.read, int64(c))
+    return c, err
+}
+
+// received returns the bytes read so far.
+func (n *numbered) received() int64 {
+    return atomic.LoadInt64(&n.read)
+}
+
+// connID returns the number of c, unwrapping TLS, or 0 when unknown.
+func connID(c net.Conn) int {
+    if tc, ok := c.(*tls.Conn); ok {
+        c = tc.NetConn()
+    }
+
+    if n, ok := c.(*numbered); ok {
+        return n.id
+    }
+    return 0
+}
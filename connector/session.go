@@ -0,0 +1,153 @@
+package connector
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Session is an ordered list of requests made by a single user, modeled
+// on httperf's --wsesslog. A session fails, and stops, at the first
+// request that errors.
+type Session struct {
+    Requests []SessionRequest
+}
+
+// SessionRequest is a request within a Session. Burst requests are
+// issued concurrently once the request itself has completed, e.g. the
+// images and stylesheets of a page. Think is the pause after the
+// request and its burst, before the next request of the Session.
+type SessionRequest struct {
+    Target
+    Burst []Target
+    Think time.Duration
+}
+
+// ReadSessions parses a session file. Sessions are separated by blank
+// lines, with one request per line, using the URL list syntax (see
+// ReadTargets) with an added think=SECONDS option:
+//
+//	/index.html think=2.5
+//	    /style.css
+//	    /logo.png
+//	POST /login body=user=jane
+//
+//	/search?q=goperf think=1
+//	/results
+//
+// Indented lines are the burst of the request preceding them. Lines
+// starting with # are ignored. Relative body files are read from dir.
+func ReadSessions(r io.Reader, dir string) ([]Session, error) {
+    var sessions []Session
+    var session Session
+
+    end := func() {
+        if len(session.Requests) > 0 {
+            sessions = append(sessions, session)
+        }
+        session = Session{}
+    }
+
+    scanner := bufio.NewScanner(r)
+    for n := 1; scanner.Scan(); n++ {
+        text := scanner.Text()
+        line := strings.TrimSpace(text)
+        if line == "" {
+            end()
+            continue
+        }
+
+        if strings.HasPrefix(line, "#") {
+            continue
+        }
+
+        request, err := parseSessionRequest(line, dir)
+        if err != nil {
+            return nil, fmt.Errorf("line %d: %v", n, err)
+        }
+
+        if text[0] != ' ' && text[0] != '\t' {
+            session.Requests = append(session.Requests, request)
+            continue
+        }
+
+        if len(session.Requests) == 0 {
+            return nil, fmt.Errorf("line %d: burst request without a preceding request", n)
+        }
+
+        if request.Think > 0 {
+            return nil, fmt.Errorf("line %d: think is not supported on burst requests", n)
+        }
+
+        last := &session.Requests[len(session.Requests)-1]
+        last.Burst = append(last.Burst, request.Target)
+    }
+    end()
+
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+
+    if len(sessions) == 0 {
+        return nil, fmt.Errorf("no sessions found")
+    }
+
+    return sessions, nil
+}
+
+// LoadSessions reads a session file, see ReadSessions.
+func LoadSessions(path string) ([]Session, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    return ReadSessions(file, filepath.Dir(path))
+}
+
+/****
+ * Private methods
+ *****************************************************/
+
+func parseSessionRequest(line, dir string) (SessionRequest, error) {
+    request := SessionRequest{}
+
+    rest := line
+    var body string
+    if loc := bodyOption.FindStringIndex(rest); loc != nil {
+        body = rest[loc[0]:]
+        rest = rest[:loc[0]]
+    }
+
+    var fields []string
+    for _, field := range strings.Fields(rest) {
+        if strings.HasPrefix(field, "weight=") {
+            return request, fmt.Errorf("weight is not supported in sessions")
+        }
+
+        if !strings.HasPrefix(field, "think=") {
+            fields = append(fields, field)
+            continue
+        }
+
+        think, err := strconv.ParseFloat(strings.TrimPrefix(field, "think="), 64)
+        if err != nil || think < 0 {
+            return request, fmt.Errorf("invalid %q, expected seconds", field)
+        }
+        request.Think = time.Duration(think * float64(time.Second))
+    }
+
+    target, err := parseTarget(strings.Join(fields, " ")+body, dir)
+    if err != nil {
+        return request, err
+    }
+    request.Target = target
+
+    return request, nil
+}
@@ -0,0 +1,77 @@
+package connector
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestReadSessions(T *testing.T) {
+    log := `
+# browse, then log in
+/index.html think=2.5
+    /style.css
+    /logo.png
+POST /login body=user=jane think=1
+
+/search?q=goperf think=0.5
+  DELETE http://localhost/cart
+/results
+`
+    sessions, err := ReadSessions(strings.NewReader(log), "")
+    Go(T).Assert(err == nil)
+    Go(T).AssertLength(sessions, 2)
+
+    first := sessions[0].Requests
+    Go(T).AssertLength(first, 2)
+    Go(T).AssertEqual(first[0].Path, "/index.html")
+    Go(T).AssertEqual(first[0].Think, 2500*time.Millisecond)
+    Go(T).AssertLength(first[0].Burst, 2)
+    Go(T).AssertEqual(first[0].Burst[1].Name(), "GET /logo.png")
+
+    // body consumes the rest of the line, think included.
+    Go(T).AssertEqual(first[1].Method, "POST")
+    Go(T).AssertEqual(string(first[1].Body), "user=jane think=1")
+    Go(T).AssertEqual(first[1].Think, time.Duration(0))
+
+    second := sessions[1].Requests
+    Go(T).AssertLength(second, 2)
+    Go(T).AssertEqual(second[0].Think, 500*time.Millisecond)
+    Go(T).AssertEqual(second[0].Burst[0].Name(), "DELETE http://localhost/cart")
+    Go(T).AssertLength(second[1].Burst, 0)
+}
+
+func TestReadSessionsErrors(T *testing.T) {
+    for _, log := range []string{
+        "",
+        "# only comments",
+        "  /burst/first",
+        "/path think=x",
+        "/path think=-1",
+        "/path weight=2",
+        "/path\n  /burst think=1",
+        "/path bogus=1",
+    } {
+        _, err := ReadSessions(strings.NewReader(log), "")
+        Go(T).Refute(err == nil, log)
+    }
+}
+
+func TestLoadSessions(T *testing.T) {
+    dir, _ := ioutil.TempDir("", "goperf")
+    defer os.RemoveAll(dir)
+
+    ioutil.WriteFile(filepath.Join(dir, "login.json"), []byte("{}"), 0644)
+    ioutil.WriteFile(filepath.Join(dir, "sessions.txt"), []byte("/a\nPUT /b body=@login.json\n"), 0644)
+
+    sessions, err := LoadSessions(filepath.Join(dir, "sessions.txt"))
+    Go(T).Assert(err == nil)
+    Go(T).AssertLength(sessions, 1)
+    Go(T).AssertEqual(string(sessions[0].Requests[1].Body), "{}")
+
+    _, err = LoadSessions(filepath.Join(dir, "missing.txt"))
+    Go(T).Refute(err == nil)
+}
@@ -0,0 +1,47 @@
+package connector
+
+import (
+    "fmt"
+    "io"
+    "net/http"
+    "strings"
+)
+
+// counter counts bytes read through it, or written to it when it has no
+// Reader.
+type counter struct {
+    io.Reader
+    n int64
+}
+
+func (c *counter) Read(p []byte) (int, error) {
+    n, err := c.Reader.Read(p)
+    c.n += int64(n)
+    return n, err
+}
+
+func (c *counter) Write(p []byte) (int, error) {
+    c.n += int64(len(p))
+    return len(p), nil
+}
+
+// headerSize estimates the size of resp's status line, header and any
+// trailer, as HTTP/1.x text, for replies whose header bytes can't be
+// counted on the connection (see trace.received), i.e. over TLS or
+// HTTP/2. It is rebuilt from resp, adding back headers the Transport
+// consumes (e.g. Transfer-Encoding). HTTP/2 headers are HPACK compressed
+// on the wire, so for them this is an overestimate.
+func headerSize(resp *http.Response) int64 {
+    c := &counter{}
+    fmt.Fprintf(c, "%s %s\r\n", resp.Proto, resp.Status)
+
+    if len(resp.TransferEncoding) > 0 {
+        fmt.Fprintf(c, "Transfer-Encoding: %s\r\n", strings.Join(resp.TransferEncoding, ", "))
+    }
+
+    resp.Header.Write(c)
+    c.n += 2
+
+    resp.Trailer.Write(c)
+    return c.n
+}
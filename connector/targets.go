@@ -0,0 +1,156 @@
+package connector
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// Selection strategies for choosing among Targets.
+const (
+    RoundRobin = "roundrobin"
+    Random     = "random"
+    Weighted   = "weighted"
+)
+
+// Target is a single request definition in a multi-URL workload. Path
+// may be relative to the Connector's Path.
+type Target struct {
+    Method string
+    Path   string
+    Body   []byte
+    Weight int
+}
+
+// Name identifies the Target in Results, e.g. "GET http://localhost/".
+func (t Target) Name() string {
+    return t.Method + " " + t.Path
+}
+
+// ReadTargets parses a URL list, with one target per line:
+//
+//	[METHOD] URL [weight=N] [body=DATA | body=@file]
+//
+// Method defaults to GET, or POST when a body is set, and weight to 1.
+// As body consumes the rest of the line it must come last. Blank lines
+// and lines starting with # are ignored. Relative body files are read
+// from dir.
+func ReadTargets(r io.Reader, dir string) ([]Target, error) {
+    var targets []Target
+
+    scanner := bufio.NewScanner(r)
+    for n := 1; scanner.Scan(); n++ {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+
+        target, err := parseTarget(line, dir)
+        if err != nil {
+            return nil, fmt.Errorf("line %d: %v", n, err)
+        }
+        targets = append(targets, target)
+    }
+
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+
+    if len(targets) == 0 {
+        return nil, fmt.Errorf("no targets found")
+    }
+
+    return targets, nil
+}
+
+// LoadTargets reads a URL list file, see ReadTargets.
+func LoadTargets(path string) ([]Target, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    return ReadTargets(file, filepath.Dir(path))
+}
+
+/****
+ * Private methods
+ *****************************************************/
+
+// bodyOption matches body= as a field, not within a URL's query.
+var bodyOption = regexp.MustCompile(`\sbody=`)
+
+func parseTarget(line, dir string) (Target, error) {
+    target := Target{Weight: 1}
+
+    rest := line
+    var body string
+    if loc := bodyOption.FindStringIndex(rest); loc != nil {
+        body = rest[loc[1]:]
+        rest = rest[:loc[0]]
+    }
+
+    fields := strings.Fields(rest)
+    if len(fields) > 0 && isMethod(fields[0]) {
+        target.Method = fields[0]
+        fields = fields[1:]
+    }
+
+    if len(fields) == 0 {
+        return target, fmt.Errorf("missing url")
+    }
+    target.Path = fields[0]
+
+    for _, field := range fields[1:] {
+        if !strings.HasPrefix(field, "weight=") {
+            return target, fmt.Errorf("unknown option %q", field)
+        }
+
+        weight, err := strconv.Atoi(strings.TrimPrefix(field, "weight="))
+        if err != nil || weight < 1 {
+            return target, fmt.Errorf("invalid %q, expected a positive integer", field)
+        }
+        target.Weight = weight
+    }
+
+    if strings.HasPrefix(body, "@") {
+        file := strings.TrimSpace(body[1:])
+        if !filepath.IsAbs(file) {
+            file = filepath.Join(dir, file)
+        }
+
+        content, err := ioutil.ReadFile(file)
+        if err != nil {
+            return target, err
+        }
+        target.Body = content
+    } else if body != "" {
+        target.Body = []byte(body)
+    }
+
+    if target.Method == "" {
+        target.Method = "GET"
+        if len(target.Body) > 0 {
+            target.Method = "POST"
+        }
+    }
+
+    return target, nil
+}
+
+// isMethod reports whether field looks like an HTTP method, e.g. GET.
+func isMethod(field string) bool {
+    for _, c := range field {
+        if c < 'A' || c > 'Z' {
+            return false
+        }
+    }
+    return field != ""
+}
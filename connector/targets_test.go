@@ -0,0 +1,80 @@
+package connector
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestReadTargets(T *testing.T) {
+    list := `
+# comment
+http://localhost/
+/relative weight=3
+POST http://localhost/api?body=x body={"hello": "web"}
+PUT /upload body=@payload.json
+DELETE /item/1
+`
+    dir, _ := ioutil.TempDir("", "goperf")
+    defer os.RemoveAll(dir)
+    ioutil.WriteFile(filepath.Join(dir, "payload.json"), []byte("payload"), 0644)
+
+    targets, err := ReadTargets(strings.NewReader(list), dir)
+    Go(T).Assert(err == nil)
+    Go(T).AssertLength(targets, 5)
+
+    Go(T).AssertEqual(targets[0].Method, "GET")
+    Go(T).AssertEqual(targets[0].Path, "http://localhost/")
+    Go(T).AssertEqual(targets[0].Weight, 1)
+
+    Go(T).AssertEqual(targets[1].Path, "/relative")
+    Go(T).AssertEqual(targets[1].Weight, 3)
+
+    Go(T).AssertEqual(targets[2].Method, "POST")
+    Go(T).AssertEqual(targets[2].Path, "http://localhost/api?body=x")
+    Go(T).AssertEqual(string(targets[2].Body), `{"hello": "web"}`)
+
+    Go(T).AssertEqual(targets[3].Method, "PUT")
+    Go(T).AssertEqual(string(targets[3].Body), "payload")
+
+    Go(T).AssertEqual(targets[4].Name(), "DELETE /item/1")
+}
+
+func TestReadTargetsBodyDefaultsToPost(T *testing.T) {
+    targets, err := ReadTargets(strings.NewReader("/api body=data"), "")
+    Go(T).Assert(err == nil)
+    Go(T).AssertEqual(targets[0].Method, "POST")
+}
+
+func TestReadTargetsErrors(T *testing.T) {
+    for _, list := range []string{
+        "",
+        "# only comments",
+        "GET",
+        "/path weight=0",
+        "/path weight=x",
+        "/path bogus=1",
+        "/path body=@does/not/exist",
+    } {
+        _, err := ReadTargets(strings.NewReader(list), "")
+        Go(T).Refute(err == nil, list)
+    }
+}
+
+func TestLoadTargets(T *testing.T) {
+    dir, _ := ioutil.TempDir("", "goperf")
+    defer os.RemoveAll(dir)
+
+    ioutil.WriteFile(filepath.Join(dir, "body.txt"), []byte("from file"), 0644)
+    ioutil.WriteFile(filepath.Join(dir, "urls.txt"), []byte("/a\n/b body=@body.txt\n"), 0644)
+
+    targets, err := LoadTargets(filepath.Join(dir, "urls.txt"))
+    Go(T).Assert(err == nil)
+    Go(T).AssertLength(targets, 2)
+    Go(T).AssertEqual(string(targets[1].Body), "from file")
+
+    _, err = LoadTargets(filepath.Join(dir, "missing.txt"))
+    Go(T).Refute(err == nil)
+}
@@ -0,0 +1,128 @@
+package connector
+
+import (
+    "crypto/tls"
+    "github.com/jmervine/goperf/results"
+    "net/http/httptrace"
+    "sync"
+    "time"
+)
+
+// trace collects phase timings for a single request via httptrace.
+// Callbacks may fire from transport goroutines, so access is locked.
+type trace struct {
+    lock sync.Mutex
+
+    dnsStart     time.Time
+    connectStart time.Time
+    tlsStart     time.Time
+    wrote        time.Time
+    firstByte    time.Time
+
+    timing results.Timing
+
+    // conn is the number of the connection used, see numbered. wire is
+    // that connection when not over TLS, and base the bytes it had read
+    // before the request.
+    conn int
+    wire *numbered
+    base int64
+}
+
+func (t *trace) clientTrace() *httptrace.ClientTrace {
+    return &httptrace.ClientTrace{
+        DNSStart: func(httptrace.DNSStartInfo) {
+            t.lock.Lock()
+            t.dnsStart = time.Now()
+            t.lock.Unlock()
+        },
+        DNSDone: func(httptrace.DNSDoneInfo) {
+            t.lock.Lock()
+            t.timing.DNS = ms(time.Since(t.dnsStart))
+            t.lock.Unlock()
+        },
+        ConnectStart: func(network, addr string) {
+            t.lock.Lock()
+            if t.connectStart.IsZero() {
+                t.connectStart = time.Now()
+            }
+            t.lock.Unlock()
+        },
+        ConnectDone: func(network, addr string, err error) {
+            t.lock.Lock()
+            if err == nil {
+                t.timing.Connect = ms(time.Since(t.connectStart))
+            }
+            t.lock.Unlock()
+        },
+        TLSHandshakeStart: func() {
+            t.lock.Lock()
+            t.tlsStart = time.Now()
+            t.lock.Unlock()
+        },
+        TLSHandshakeDone: func(tls.ConnectionState, error) {
+            t.lock.Lock()
+            t.timing.TLS = ms(time.Since(t.tlsStart))
+            t.lock.Unlock()
+        },
+        WroteRequest: func(httptrace.WroteRequestInfo) {
+            t.lock.Lock()
+            t.wrote = time.Now()
+            t.lock.Unlock()
+        },
+        GotConn: func(info httptrace.GotConnInfo) {
+            t.lock.Lock()
+            t.conn = connID(info.Conn)
+            if n, ok := info.Conn.(*numbered); ok {
+                t.wire, t.base = n, n.received()
+            }
+            t.lock.Unlock()
+        },
+        GotFirstResponseByte: func() {
+            t.lock.Lock()
+            t.firstByte = time.Now()
+            t.lock.Unlock()
+        },
+    }
+}
+
+// done completes the timing, with end being when the body was read.
+func (t *trace) done(end time.Time) results.Timing {
+    t.lock.Lock()
+    defer t.lock.Unlock()
+
+    timing := t.timing
+    if !t.firstByte.IsZero() {
+        if !t.wrote.IsZero() {
+            timing.TTFB = ms(t.firstByte.Sub(t.wrote))
+        }
+        timing.Transfer = ms(end.Sub(t.firstByte))
+    }
+
+    return timing
+}
+
+// connection returns the number of the connection used, 0 if unknown.
+func (t *trace) connection() int {
+    t.lock.Lock()
+    defer t.lock.Unlock()
+    return t.conn
+}
+
+// received returns the bytes read on a connection not over TLS during
+// the request, false when unknown. Only exact for HTTP/1.x, where a
+// connection serves one request at a time.
+func (t *trace) received() (int64, bool) {
+    t.lock.Lock()
+    defer t.lock.Unlock()
+
+    if t.wire == nil {
+        return 0, false
+    }
+    return t.wire.received() - t.base, true
+}
+
+// ms converts a time.Duration to fractional milliseconds.
+func ms(d time.Duration) float64 {
+    return float64(d) / float64(time.Millisecond)
+}
@@ -7,17 +7,74 @@ CLI Usage:
 
     $ ./goperf-v0.0.1 -help
     Usage of ./goperf-v0.0.1:
+      -H=[]: Request header, e.g. 'Accept: text/html' (repeatable).
+      -X="": Request method (default GET, or POST when -d is set).
+      -arrival="": Arrival process with -r, constant, poisson, uniform[:MIN,MAX] or burst[:N] (default constant).
+      -buckets=[]: Histogram bucket bounds in ms, e.g. 10,50,100 (default log-linear).
+      -c=0: Concurrency, keep this many requests in flight (ignores -r).
+      -cacert="": Trusted CA bundle (PEM), in place of the system roots.
+      -cert="": Client certificate (PEM), with -key.
+      -ciphers=[]: TLS 1.0 to 1.2 cipher suites, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,...
+      -connect-timeout=0: Connect (and TLS handshake) timeout, e.g. 2s.
+      -conns-per-host=0: Max connections per host with -keep-alive (0 is unlimited), or connections with -proto h2 or h2c.
+      -consume="": Response bodies, discard, hash (SHA-256, in -log) or keep (default discard, keep with -expect-body or -expect-regex).
+      -cookies=false: Keep cookies per virtual user (session, or -c worker).
+      -d="": Request body, use '@file' to read the body from a file.
+      -discard=false: Keep no raw samples, for bounded memory on long runs.
+      -expect-body="": Fail responses whose body does not contain this text.
+      -expect-header="": Fail responses without this header, 'Name' or 'Name: value'.
+      -expect-regex="": Fail responses whose body does not match this regexp.
+      -expect-status=[]: Fail responses without one of these status codes, e.g. 200,204.
+      -header-timeout=0: Response header timeout, e.g. 5s.
+      -insecure=false: Skip TLS certificate verification.
+      -keep-alive=false: Reuse connections, rather than one connection per request.
+      -keep-bytes=0: Bytes of each body kept for checks with -consume=keep (0 keeps all).
+      -key="": Client certificate key (PEM), with -cert.
+      -log="": Write a per-request log, as JSON Lines for .jsonl files, otherwise CSV.
+      -max-size=0: Fail responses with a body larger than this, in bytes.
       -n=0: Total number of connections.
+      -o="text": Output format, text or json.
+      -percentiles=[]: Percentiles to report, e.g. 50,75,99,99.9,99.99 (default 85,90,95,99).
+      -precision=3: Percentile precision, in significant figures (1 to 5).
+      -proto="http1.1": Protocol, http1.1, h2 or h2c (HTTP/2 multiplexes over -conns-per-host connections, default 1).
       -r=0: Connection rate (per second).
+      -raw=false: Include raw samples in json output.
+      -seed=0: Random seed for -arrival and -select random (0 seeds from the clock).
+      -select="roundrobin": URL selection with -urls, roundrobin, random or weighted.
+      -servername="": TLS server name (SNI) and verified name, in place of the URL host.
+      -sessions="": Session file, see connector.ReadSessions (-n, -r and -c then apply to sessions).
+      -t=0: Test duration, e.g. 60s (stops at -n or -t, whichever is first).
+      -threshold=[]: Exit 3 unless met, e.g. 'p99<250ms', 'errors<1%' or 'rps>500' (repeatable).
+      -thresholds="": Threshold file, one -threshold rule per line.
+      -timeout=0: Overall request timeout, e.g. 10s.
+      -tls-max="": Maximum TLS version, 1.0 to 1.3.
+      -tls-min="": Minimum TLS version, 1.0 to 1.3.
       -u="": Target URL.
+      -urls="": URL list file, one "[METHOD] URL [weight=N] [body=DATA|@file]" per line.
       -v=false: Print verbose messaging.
       -version=false: Show version infomration.
 
+Compare two runs saved with -o json, exiting 3 on regressions:
+
+    $ ./goperf-v0.0.1 compare -tolerance=5 old.json new.json
+
 */
 package perf
 
 import (
+    "context"
+    "crypto/tls"
+    "crypto/x509"
     "fmt"
+    "io/ioutil"
+    "math/rand"
+    "net/http"
+    "net/url"
+    "os"
+    "regexp"
+    "sort"
+    "strings"
+    "time"
     "github.com/jmervine/goperf/connector"
     "github.com/jmervine/goperf/results"
 )
@@ -34,114 +91,824 @@ type Configurator struct {
     NumConns int
     Path     string
     Verbose  bool
+
+    // Quiet hides the "Running: ..." header and sends Verbose messaging
+    // to stderr, keeping stdout for e.g. JSON output.
+    Quiet bool
+
+    // Duration runs requests until time expires, see connector.Duration.
+    Duration time.Duration
+
+    // Concurrency runs a fixed pool of workers, see connector.Concurrency.
+    Concurrency int
+
+    // Arrival spaces requests at Rate as constant (the default), poisson,
+    // uniform (or uniform:MIN,MAX) or burst (or burst:N), see
+    // connector.ParseArrival. Seed, when not zero, makes random arrivals
+    // and selection reproducible.
+    Arrival string
+    Seed    int64
+
+    // KeepAlive and ConnsPerHost control connection reuse, see
+    // connector.KeepAlive.
+    KeepAlive    bool
+    ConnsPerHost int
+
+    // Protocol is http1.1 (the default), h2 or h2c, see
+    // connector.Protocol. HTTP/2 multiplexes requests over ConnsPerHost
+    // connections. h2 requires https URLs, and h2c http URLs.
+    Protocol string
+
+    // Timeout, ConnectTimeout and HeaderTimeout limit requests, see
+    // connector.Timeout. Timed out requests are counted as errors.
+    Timeout        time.Duration
+    ConnectTimeout time.Duration
+    HeaderTimeout  time.Duration
+
+    // Method defaults to GET, or POST when a Body or BodyFile is set.
+    Method string
+
+    // Headers are raw header lines, e.g. "Content-Type: application/json".
+    Headers []string
+
+    // Body is sent with each request, BodyFile is read in its place
+    // when set.
+    Body     string
+    BodyFile string
+
+    // URLFile is a multi-URL workload, see connector.ReadTargets, chosen
+    // per request by Selection. Path is optional with a URLFile, being
+    // the base for relative URLs.
+    URLFile   string
+    Selection string
+
+    // SessionFile is a session workload, see connector.ReadSessions.
+    // NumConns, Rate and Concurrency then apply to sessions rather than
+    // requests. Path is optional with a SessionFile, as with URLFile.
+    SessionFile string
+
+    // Cookies keeps a cookie jar per virtual user, see connector.Cookies.
+    Cookies bool
+
+    // ExpectStatus, ExpectBody, ExpectPattern (a regexp), ExpectHeader
+    // and MaxSize validate responses, see connector.Check. Failures are
+    // counted as errors.
+    ExpectStatus  []int
+    ExpectBody    string
+    ExpectPattern string
+    ExpectHeader  string
+    MaxSize       int64
+
+    // CAFile and CertFile with KeyFile (PEM) set trusted CAs and a client
+    // certificate, Insecure skips verification, and ServerName overrides
+    // SNI and the verified name. TLSMin and TLSMax pin versions, e.g.
+    // "1.2", and Ciphers the TLS 1.0 to 1.2 cipher suites, by name (e.g.
+    // TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256). See connector.TLS.
+    CAFile     string
+    CertFile   string
+    KeyFile    string
+    Insecure   bool
+    ServerName string
+    TLSMin     string
+    TLSMax     string
+    Ciphers    []string
+
+    // Consume sets how response bodies are read, discard, hash or keep
+    // (the first KeepBytes), see connector.Consume.
+    Consume   string
+    KeepBytes int64
+
+    // Buckets are the latency histogram bounds, in ms, see
+    // results.Histogram. Defaults to results.DefaultBuckets.
+    Buckets []float64
+
+    // Precision of percentiles recorded by results.Recorder, in
+    // significant figures. Defaults to results.DefaultPrecision.
+    Precision int
+
+    // Percentiles are those reported, e.g. 99.9, see results.Percentiles.
+    Percentiles []float64
+
+    // Discard drops raw samples for bounded memory on long runs, see
+    // results.Discard.
+    Discard bool
+
+    // Log receives a Record per request, see results.NewCSVWriter and
+    // results.NewJSONLWriter.
+    Log results.Writer
+}
+
+// ValidationError is returned by the Try* functions when a
+// Configurator is invalid.
+type ValidationError struct {
+    Field   string
+    Message string
+}
+
+func (e *ValidationError) Error() string {
+    return e.Field + ": " + e.Message
 }
 
 // QuickRun limited options.
 func QuickRun(path string, numconns int, rate float64) *results.Results {
+    return must(TryQuickRun(path, numconns, rate))
+}
+
+// Siege forces Parallel run, with limited options.
+func Siege(path string, numconns int) *results.Results {
+    return must(TrySiege(path, numconns))
+}
+
+// Start a new run using a Configurator
+func Start(config *Configurator) *results.Results {
+    return must(TryStart(config))
+}
+
+// Parallel forces a parallel run using a Configurator.
+func Parallel(config *Configurator) *results.Results {
+    return must(TryParallel(config))
+}
+
+// Series forces a run using a Configurator, running request in series.
+func Series(config *Configurator) *results.Results {
+    return must(TrySeries(config))
+}
+
+// Connect makes a singled connection, returning a simplified result struct.
+func Connect(path string, verbose bool) *results.Result {
+    result, err := TryConnect(path, verbose)
+    if err != nil {
+        panic(err)
+    }
+    return result
+}
+
+// TryQuickRun is QuickRun, returning an error rather than panicking.
+func TryQuickRun(path string, numconns int, rate float64) (*results.Results, error) {
     config := &Configurator{
         Path:     path,
         NumConns: numconns,
         Rate:     rate,
     }
 
-    return Start(config)
+    return TryStart(config)
 }
 
-// Siege forces Parallel run, with limited options.
-func Siege(path string, numconns int) *results.Results {
+// TrySiege is Siege, returning an error rather than panicking.
+func TrySiege(path string, numconns int) (*results.Results, error) {
     config := &Configurator{
         Path:     path,
         NumConns: numconns,
     }
 
-    return Parallel(config)
+    return TryParallel(config)
 }
 
-// Start a new run using a Configurator
-func Start(config *Configurator) *results.Results {
-    conn := setup(config)
-    conn.Run()
-    return conn.Results
+// TryStart is Start, returning an error rather than panicking.
+func TryStart(config *Configurator) (*results.Results, error) {
+    return StartContext(context.Background(), config)
 }
 
-// Parallel forces a parallel run using a Configurator.
-func Parallel(config *Configurator) *results.Results {
-    conn := setup(config)
-    conn.Parallel()
-    return conn.Results
+// TryParallel is Parallel, returning an error rather than panicking.
+func TryParallel(config *Configurator) (*results.Results, error) {
+    return ParallelContext(context.Background(), config)
 }
 
-// Series forces a run using a Configurator, running request in series.
-func Series(config *Configurator) *results.Results {
-    conn := setup(config)
-    conn.Series()
-    return conn.Results
+// TrySeries is Series, returning an error rather than panicking.
+func TrySeries(config *Configurator) (*results.Results, error) {
+    return SeriesContext(context.Background(), config)
 }
 
-// Connect makes a singled connection, returning a simplified result struct.
-func Connect(path string, verbose bool) *results.Result {
-    conn := connector.Connector{}.New(path, 0)
+// StartContext is TryStart, stopping early when ctx is cancelled. The
+// finalized partial results are returned, marked Interrupted.
+func StartContext(ctx context.Context, config *Configurator) (*results.Results, error) {
+    conn, err := setup(config)
+    if err != nil {
+        return nil, err
+    }
+
+    conn.RunContext(ctx)
+    return conn.Results, nil
+}
+
+// ParallelContext is TryParallel, stopping early when ctx is cancelled.
+// Duration requires Rate or Arrival, to pace requests.
+func ParallelContext(ctx context.Context, config *Configurator) (*results.Results, error) {
+    if config.Duration > 0 && config.Rate <= 0 && config.Arrival == "" {
+        return nil, &ValidationError{Field: "Rate",
+            Message: "is required for parallel runs with Duration, unless Arrival is set"}
+    }
+
+    conn, err := setup(config)
+    if err != nil {
+        return nil, err
+    }
+
+    conn.ParallelContext(ctx)
+    return conn.Results, nil
+}
+
+// SeriesContext is TrySeries, stopping early when ctx is cancelled.
+func SeriesContext(ctx context.Context, config *Configurator) (*results.Results, error) {
+    conn, err := setup(config)
+    if err != nil {
+        return nil, err
+    }
+
+    conn.SeriesContext(ctx)
+    return conn.Results, nil
+}
+
+// TryConnect is Connect, returning an error rather than panicking. The
+// error is only for an unusable path, request errors are reported in
+// the Result.
+func TryConnect(path string, verbose bool) (*results.Result, error) {
+    conn, err := connector.New(path, 0)
+    if err != nil {
+        return nil, err
+    }
     conn.Verbose = verbose
 
     result := conn.Connect()
-    return &result
+    return &result, nil
 }
 
 // Display formatted results.
 func Display(r *results.Results) {
-    fmt.Printf("Total: requested %d replies %d test-duration %6.2fs\n",
-        r.Requested, len(r.Took), r.TotalTime)
+    if r.Interrupted {
+        fmt.Println("Interrupted: partial results for completed requests")
+    }
+    fmt.Printf("Total: connections %d requested %d replies %d test-duration %6.2fs\n",
+        r.Connections, r.Requested, r.Replies, r.TotalTime)
+    if r.Concurrency > 0 {
+        fmt.Printf("Concurrency: %d\n", r.Concurrency)
+    }
     fmt.Println()
 
     fmt.Printf("Connection rate: %6.2f conn/s\n", r.ConnPerSec)
+    fmt.Printf("Request rate: %6.2f req/s\n", r.ReqPerSec)
     fmt.Printf("Connection time [ms]: min %6.2f avg %6.2f max %6.2f med %6.2f\n",
         r.TookMin, r.TookAvg, r.TookMax, r.TookMed)
-    fmt.Printf("Connection time [ms]: 85th %6.2f 90th %6.2f 95th %6.2f 99th %6.2f\n",
-        r.Took85th, r.Took90th, r.Took95th, r.Took99th)
+    fmt.Printf("Connection time [ms]: %s\n", formatPercentiles(r.TookStats().Percentiles))
     fmt.Printf("Connection time [ms]: connect %6.2f\n", r.ConnectTime)
     fmt.Println()
 
-    fmt.Printf("Reply size [B]: content %v header/footer %v (total %v)\n",
-        r.ContentLength, r.HeaderLength, r.TotalLength)
+    // Corrected for coordinated omission, on Rate scheduled runs.
+    if r.Response.Count > 0 {
+        fmt.Printf("Response time [ms]: min %6.2f avg %6.2f max %6.2f med %6.2f\n",
+            r.Response.Min, r.Response.Avg, r.Response.Max, r.Response.Med)
+        fmt.Printf("Response time [ms]: %s\n", formatPercentiles(r.Response.Percentiles))
+        fmt.Println()
+    }
+
+    displayPhase("dns", r.DNS)
+    displayPhase("connect", r.Connect)
+    displayPhase("tls", r.TLS)
+    displayPhase("ttfb", r.TTFB)
+    displayPhase("transfer", r.Transfer)
+    fmt.Println()
+
+    if r.Histogram != nil && r.Histogram.Total > 0 {
+        fmt.Println("Histogram [ms]:")
+        r.Histogram.Render(os.Stdout, 40)
+        fmt.Println()
+    }
+
+    // Header sizes over TLS or HTTP/2 are estimated.
+    estimated := ""
+    if r.HeaderEstimated > 0 {
+        estimated = " (est.)"
+    }
+
+    fmt.Printf("Reply size [B]: content %.1f header/footer%s %.1f (total %.1f)\n",
+        r.ContentSize.Avg, estimated, r.HeaderSize.Avg, r.TotalSize.Avg)
+    fmt.Printf("Reply size [B]: min %d max %d received %d\n",
+        r.TotalSize.Min, r.TotalSize.Max, r.TotalLength)
+    fmt.Printf("Net I/O: %.1f KB/s received\n", r.KBPerSec)
     fmt.Printf("Reply status: 1xx=%d 2xx=%d 3xx=%d 4xx=%d 5xx=%d\n",
         r.Code1xx, r.Code2xx, r.Code3xx, r.Code4xx, r.Code5xx)
+    if len(r.Protocols) > 0 {
+        fmt.Printf("Reply protocol: %s\n", formatCounts(r.Protocols))
+    }
+
+    if r.StreamsPerConn.Count > 0 {
+        fmt.Printf("Streams per connection: conns %d min %d avg %.1f max %d\n",
+            r.StreamsPerConn.Count, r.StreamsPerConn.Min, r.StreamsPerConn.Avg, r.StreamsPerConn.Max)
+    }
+    fmt.Println()
+
+    fmt.Printf("Errors: total %d client-timeout %d conn-timeout %d conn-refused %d conn-reset %d\n",
+        r.ErrorsTotal, r.ErrorsClientTimeout, r.ErrorsConnTimeout, r.ErrorsConnRefused,
+        r.ErrorsConnReset)
+    fmt.Printf("Errors: fd-unavail %d addr-unavail %d canceled %d check-failed %d other %d\n",
+        r.ErrorsFdUnavail, r.ErrorsAddrUnavail, r.ErrorsCanceled, r.ErrorsCheckFailed, r.ErrorsOther)
+    fmt.Println()
+
+    if len(r.TLSVersions) > 0 {
+        displayTLS(r)
+    }
+
+    if r.Sessions > 0 {
+        displaySessions(r)
+    }
+
+    if len(r.Targets) > 0 {
+        displayTargets(r.Targets)
+    }
+}
+
+// DisplayJSON writes results as JSON, including raw Took and Code
+// samples when raw is true.
+func DisplayJSON(r *results.Results, raw bool) error {
+    return r.WriteJSON(os.Stdout, raw)
+}
+
+// DisplayComparison prints a Comparison, marking changes beyond its
+// tolerance.
+func DisplayComparison(c *results.Comparison) {
+    fmt.Printf("Comparison: tolerance %.2f%%\n", c.Tolerance)
     fmt.Println()
 
-    fmt.Printf("Errors: total %d conn-timeout %d conn-refused %d conn-reset %d\n",
-        r.ErrorsTotal, r.ErrorsConnTimeout, r.ErrorsConnRefused, r.ErrorsConnReset)
-    fmt.Printf("Errors: fd-unavail %d addr-unavail %d other %d\n",
-        r.ErrorsFdUnavail, r.ErrorsAddrUnavail, r.ErrorsOther)
+    for _, d := range c.Deltas {
+        relative := "(n/a)"
+        if d.Old != 0 {
+            relative = fmt.Sprintf("(%+.2f%%)", d.Relative)
+        }
+
+        mark := ""
+        if d.Regression {
+            mark = " regression"
+        } else if d.Exceeded {
+            mark = " improvement"
+        }
+
+        fmt.Printf("%-22s old %10.2f new %10.2f delta %+10.2f %10s%s\n",
+            d.Metric, d.Old, d.New, d.Absolute, relative, mark)
+    }
     fmt.Println()
+
+    fmt.Printf("Regressions: %d\n", len(c.Regressions()))
 }
 
 /****
  * Private methods
  *****************************************************/
 
+func displayTargets(targets map[string]*results.Results) {
+    names := make([]string, 0, len(targets))
+    for name := range targets {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    for _, name := range names {
+        t := targets[name]
+        fmt.Printf("Target: %s\n", name)
+        fmt.Printf("  replies %d rate %6.2f req/s errors %d\n", t.Replies, t.ReqPerSec, t.ErrorsTotal)
+        fmt.Printf("  time [ms]: min %6.2f avg %6.2f max %6.2f med %6.2f %s\n",
+            t.TookMin, t.TookAvg, t.TookMax, t.TookMed, formatPercentiles(t.TookStats().Percentiles))
+        fmt.Printf("  status: 1xx=%d 2xx=%d 3xx=%d 4xx=%d 5xx=%d\n",
+            t.Code1xx, t.Code2xx, t.Code3xx, t.Code4xx, t.Code5xx)
+    }
+    fmt.Println()
+}
+
+func displaySessions(r *results.Results) {
+    fmt.Printf("Session rate: %6.2f sess/s\n", r.SessionPerSec)
+    fmt.Printf("Session: started %d completed %d failed %d\n",
+        r.Sessions, r.SessionsCompleted, r.SessionsFailed)
+    fmt.Printf("Session time [ms]: min %6.2f avg %6.2f max %6.2f med %6.2f %s\n",
+        r.Session.Min, r.Session.Avg, r.Session.Max, r.Session.Med, formatPercentiles(r.Session.Percentiles))
+    fmt.Println()
+}
+
+func displayTLS(r *results.Results) {
+    fmt.Printf("TLS: %s resumed %d\n", formatCounts(r.TLSVersions), r.TLSResumed)
+    fmt.Printf("TLS cipher: %s\n", formatCounts(r.TLSCiphers))
+    fmt.Println()
+}
+
+// formatCounts formats counts as e.g. "TLS 1.2=3 TLS 1.3=7", by name.
+func formatCounts(counts map[string]int) string {
+    names := make([]string, 0, len(counts))
+    for name := range counts {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    fields := make([]string, 0, len(names))
+    for _, name := range names {
+        fields = append(fields, fmt.Sprintf("%s=%d", name, counts[name]))
+    }
+    return strings.Join(fields, " ")
+}
+
+func displayPhase(name string, s results.Stats) {
+    fmt.Printf("Phase time [ms]: %-8s min %6.2f avg %6.2f max %6.2f med %6.2f %s (%d)\n",
+        name, s.Min, s.Avg, s.Max, s.Med, formatPercentiles(s.Percentiles), s.Count)
+}
+
+// formatPercentiles formats percentiles as e.g. "99th 12.50 99.9th 30.00".
+func formatPercentiles(percentiles []results.Percentile) string {
+    fields := make([]string, 0, len(percentiles))
+    for _, p := range percentiles {
+        fields = append(fields, fmt.Sprintf("%vth %6.2f", p.Pct, p.Value))
+    }
+    return strings.Join(fields, " ")
+}
+
 // Setup Connector via Configurator
-func setup(config *Configurator) *connector.Connector {
-    validate(config)
+func setup(config *Configurator) (*connector.Connector, error) {
+    if err := validate(config); err != nil {
+        return nil, err
+    }
+
+    var targets []connector.Target
+    if config.URLFile != "" {
+        var err error
+        if targets, err = connector.LoadTargets(config.URLFile); err != nil {
+            return nil, &ValidationError{Field: "URLFile", Message: err.Error()}
+        }
+    }
+
+    var sessions []connector.Session
+    if config.SessionFile != "" {
+        var err error
+        if sessions, err = connector.LoadSessions(config.SessionFile); err != nil {
+            return nil, &ValidationError{Field: "SessionFile", Message: err.Error()}
+        }
+    }
+
+    path := config.Path
+    if path == "" && len(targets) > 0 {
+        path = targets[0].Path
+    }
+
+    if path == "" && len(sessions) > 0 {
+        path = sessions[0].Requests[0].Path
+    }
+
+    conn, err := connector.New(path, config.NumConns)
+    if err != nil {
+        return nil, &ValidationError{Field: "Path", Message: err.Error()}
+    }
+
+    paths := []string{conn.Path}
+    for _, target := range targets {
+        paths = append(paths, target.Path)
+    }
+
+    for _, session := range sessions {
+        for _, req := range session.Requests {
+            paths = append(paths, req.Path)
+            for _, burst := range req.Burst {
+                paths = append(paths, burst.Path)
+            }
+        }
+    }
+
+    if err := protocolScheme(config.Protocol, paths); err != nil {
+        return nil, err
+    }
+
+    content, err := body(config)
+    if err != nil {
+        return nil, &ValidationError{Field: "BodyFile", Message: err.Error()}
+    }
+
+    validation, err := check(config)
+    if err != nil {
+        return nil, &ValidationError{Field: "ExpectPattern", Message: err.Error()}
+    }
+
+    secure, err := tlsConfig(config)
+    if err != nil {
+        return nil, err
+    }
+
+    schedule, err := arrival(config)
+    if err != nil {
+        return nil, &ValidationError{Field: "Arrival", Message: err.Error()}
+    }
+
     header(config)
-    conn := connector.Connector{}.New(config.Path, config.NumConns)
     conn.Rate = config.Rate
     conn.Verbose = config.Verbose
-    return &conn
+    if config.Quiet {
+        conn.Messages = os.Stderr
+    }
+    conn.Duration = config.Duration
+    conn.Concurrency = config.Concurrency
+    conn.Arrival = schedule
+    conn.Seed = config.Seed
+    conn.KeepAlive = config.KeepAlive
+    conn.ConnsPerHost = config.ConnsPerHost
+    conn.Protocol = config.Protocol
+    conn.Timeout = config.Timeout
+    conn.ConnectTimeout = config.ConnectTimeout
+    conn.HeaderTimeout = config.HeaderTimeout
+    conn.Method = method(config)
+    conn.Header = parseHeaders(config.Headers)
+    conn.Body = content
+    conn.Log = config.Log
+    conn.Targets = targets
+    conn.Selection = config.Selection
+    conn.Sessions = sessions
+    conn.Cookies = config.Cookies
+    conn.Check = validation
+    conn.TLS = secure
+    conn.Consume = config.Consume
+    conn.KeepBytes = config.KeepBytes
+
+    if len(config.Buckets) > 0 {
+        conn.Results.Histogram = results.NewHistogram(config.Buckets)
+    }
+
+    if config.Precision != 0 {
+        if conn.Results.Latency, err = results.NewRecorder(config.Precision); err != nil {
+            return nil, &ValidationError{Field: "Precision", Message: err.Error()}
+        }
+    }
+
+    conn.Results.Percentiles = config.Percentiles
+
+    if config.Discard {
+        conn.Results.Discard = true
+        conn.Results.Took, conn.Results.Code = nil, nil
+    }
+    return &conn, nil
 }
 
-func validate(config *Configurator) {
-    if config.Path == "" {
-        panic("Path is required.")
+func validate(config *Configurator) error {
+    if config.Path == "" && config.URLFile == "" && config.SessionFile == "" {
+        return &ValidationError{Field: "Path",
+            Message: "is required, unless URLFile or SessionFile is set"}
+    }
+
+    if config.URLFile != "" && config.SessionFile != "" {
+        return &ValidationError{Field: "SessionFile", Message: "cannot be used with URLFile"}
+    }
+
+    switch config.Selection {
+    case "", connector.RoundRobin, connector.Random, connector.Weighted:
+    default:
+        return &ValidationError{Field: "Selection",
+            Message: fmt.Sprintf("unknown selection %q", config.Selection)}
+    }
+
+    if config.NumConns < 0 {
+        return &ValidationError{Field: "NumConns", Message: "cannot be negative"}
+    }
+
+    if config.Duration < 0 {
+        return &ValidationError{Field: "Duration", Message: "cannot be negative"}
+    }
+
+    if config.NumConns == 0 && config.Duration == 0 {
+        return &ValidationError{Field: "NumConns",
+            Message: "is required and cannot be zero, unless Duration is set"}
+    }
+
+    if config.Concurrency < 0 {
+        return &ValidationError{Field: "Concurrency", Message: "cannot be negative"}
     }
 
-    if config.NumConns == 0 {
-        panic("NumConns is required and cannot be zero.")
+    for _, pct := range config.Percentiles {
+        if pct <= 0 || pct > 100 {
+            return &ValidationError{Field: "Percentiles",
+                Message: fmt.Sprintf("invalid percentile %v, expected above 0 and up to 100", pct)}
+        }
+    }
+
+    for _, bound := range config.Buckets {
+        if bound <= 0 {
+            return &ValidationError{Field: "Buckets", Message: "bounds must be positive"}
+        }
+    }
+
+    if config.MaxSize < 0 {
+        return &ValidationError{Field: "MaxSize", Message: "cannot be negative"}
+    }
+
+    switch config.Protocol {
+    case "", connector.HTTP1, connector.HTTP2, connector.H2C:
+    default:
+        return &ValidationError{Field: "Protocol",
+            Message: fmt.Sprintf("unknown protocol %q, expected http1.1, h2 or h2c", config.Protocol)}
+    }
+
+    switch config.Consume {
+    case "", connector.Keep:
+    case connector.Discard, connector.Hash:
+        if config.ExpectBody != "" || config.ExpectPattern != "" {
+            return &ValidationError{Field: "Consume",
+                Message: fmt.Sprintf("%s keeps no body for ExpectBody or ExpectPattern", config.Consume)}
+        }
+    default:
+        return &ValidationError{Field: "Consume",
+            Message: fmt.Sprintf("unknown consumer %q", config.Consume)}
+    }
+
+    if config.KeepBytes < 0 {
+        return &ValidationError{Field: "KeepBytes", Message: "cannot be negative"}
+    }
+
+    if (config.CertFile == "") != (config.KeyFile == "") {
+        return &ValidationError{Field: "CertFile", Message: "CertFile and KeyFile must be set together"}
+    }
+
+    for _, pin := range [][2]string{{"TLSMin", config.TLSMin}, {"TLSMax", config.TLSMax}} {
+        if _, ok := tlsVersions[pin[1]]; pin[1] != "" && !ok {
+            return &ValidationError{Field: pin[0],
+                Message: fmt.Sprintf("unknown TLS version %q, expected 1.0 to 1.3", pin[1])}
+        }
+    }
+
+    if config.TLSMin != "" && config.TLSMax != "" && tlsVersions[config.TLSMin] > tlsVersions[config.TLSMax] {
+        return &ValidationError{Field: "TLSMax", Message: "cannot be below TLSMin"}
+    }
+
+    for _, name := range config.Ciphers {
+        if _, ok := cipherSuite(name); !ok {
+            return &ValidationError{Field: "Ciphers",
+                Message: fmt.Sprintf("unknown cipher suite %q", name)}
+        }
+    }
+
+    if config.Timeout < 0 || config.ConnectTimeout < 0 || config.HeaderTimeout < 0 {
+        return &ValidationError{Field: "Timeout", Message: "timeouts cannot be negative"}
+    }
+
+    for _, line := range config.Headers {
+        if !strings.Contains(line, ":") {
+            return &ValidationError{Field: "Headers",
+                Message: fmt.Sprintf("invalid header %q, expected 'Name: value'", line)}
+        }
+    }
+
+    return nil
+}
+
+// arrival returns the Arrival for config, or nil when unset. Its random
+// source is its own, seeded from Seed or the clock, as Parallel draws
+// arrivals while requests draw Selection.
+func arrival(config *Configurator) (connector.Arrival, error) {
+    if config.Arrival == "" {
+        return nil, nil
+    }
+
+    seed := config.Seed
+    if seed == 0 {
+        seed = time.Now().UnixNano()
+    }
+
+    return connector.ParseArrival(config.Arrival, config.Rate, rand.New(rand.NewSource(seed)))
+}
+
+// protocolScheme checks protocol against the scheme of each absolute
+// path, h2 requiring https and h2c http, as the Transport would
+// otherwise fall back to HTTP/1.1.
+func protocolScheme(protocol string, paths []string) error {
+    want := map[string]string{connector.HTTP2: "https", connector.H2C: "http"}[protocol]
+    if want == "" {
+        return nil
+    }
+
+    for _, path := range paths {
+        if uri, err := url.Parse(path); err == nil && uri.Scheme != "" && uri.Scheme != want {
+            return &ValidationError{Field: "Protocol",
+                Message: fmt.Sprintf("%s requires %s URLs, not %q", protocol, want, path)}
+        }
+    }
+
+    return nil
+}
+
+func method(config *Configurator) string {
+    if config.Method != "" {
+        return strings.ToUpper(config.Method)
+    }
+
+    if config.Body != "" || config.BodyFile != "" {
+        return "POST"
+    }
+
+    return "GET"
+}
+
+func parseHeaders(lines []string) http.Header {
+    header := http.Header{}
+    for _, line := range lines {
+        parts := strings.SplitN(line, ":", 2)
+        header.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+    }
+    return header
+}
+
+func body(config *Configurator) ([]byte, error) {
+    if config.BodyFile != "" {
+        return ioutil.ReadFile(config.BodyFile)
+    }
+
+    return []byte(config.Body), nil
+}
+
+// check builds the response Check, nil when no rules are set.
+func check(config *Configurator) (*connector.Check, error) {
+    c := &connector.Check{
+        Status:  config.ExpectStatus,
+        Body:    config.ExpectBody,
+        Header:  config.ExpectHeader,
+        MaxSize: config.MaxSize,
+    }
+
+    if config.ExpectPattern != "" {
+        pattern, err := regexp.Compile(config.ExpectPattern)
+        if err != nil {
+            return nil, err
+        }
+        c.Pattern = pattern
+    }
+
+    if len(c.Status) == 0 && c.Body == "" && c.Pattern == nil && c.Header == "" && c.MaxSize == 0 {
+        return nil, nil
+    }
+
+    return c, nil
+}
+
+// tlsVersions are the Configurator's TLS versions.
+var tlsVersions = map[string]uint16{
+    "1.0": tls.VersionTLS10,
+    "1.1": tls.VersionTLS11,
+    "1.2": tls.VersionTLS12,
+    "1.3": tls.VersionTLS13,
+}
+
+// tlsConfig builds the TLS configuration, nil when no options are set.
+func tlsConfig(config *Configurator) (*tls.Config, error) {
+    if config.CAFile == "" && config.CertFile == "" && !config.Insecure && config.ServerName == "" &&
+        config.TLSMin == "" && config.TLSMax == "" && len(config.Ciphers) == 0 {
+        return nil, nil
+    }
+
+    c := &tls.Config{
+        InsecureSkipVerify: config.Insecure,
+        ServerName:         config.ServerName,
+        MinVersion:         tlsVersions[config.TLSMin],
+        MaxVersion:         tlsVersions[config.TLSMax],
+    }
+
+    for _, name := range config.Ciphers {
+        id, _ := cipherSuite(name)
+        c.CipherSuites = append(c.CipherSuites, id)
+    }
+
+    if config.CAFile != "" {
+        pem, err := ioutil.ReadFile(config.CAFile)
+        if err != nil {
+            return nil, &ValidationError{Field: "CAFile", Message: err.Error()}
+        }
+
+        c.RootCAs = x509.NewCertPool()
+        if !c.RootCAs.AppendCertsFromPEM(pem) {
+            return nil, &ValidationError{Field: "CAFile", Message: "no PEM certificates found"}
+        }
+    }
+
+    if config.CertFile != "" {
+        cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+        if err != nil {
+            return nil, &ValidationError{Field: "CertFile", Message: err.Error()}
+        }
+        c.Certificates = []tls.Certificate{cert}
+    }
+
+    return c, nil
+}
+
+// cipherSuite looks up a cipher suite by name, including insecure ones.
+func cipherSuite(name string) (uint16, bool) {
+    for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+        if suite.Name == name {
+            return suite.ID, true
+        }
+    }
+    return 0, false
+}
+
+func must(rs *results.Results, err error) *results.Results {
+    if err != nil {
+        panic(err)
     }
+    return rs
 }
 
 func header(config *Configurator) {
-    // Hide header when testing.
-    if !Testing {
-        fmt.Printf("Running: Path=%s NumConns=%d Rate=%v Verbose=%v\n\n",
-            config.Path, config.NumConns, config.Rate, config.Verbose)
+    // Hide header when testing or asked to be quiet.
+    if !Testing && !config.Quiet {
+        fmt.Printf("Running: Method=%s Path=%s URLFile=%s SessionFile=%s NumConns=%d Duration=%v Rate=%v Concurrency=%d Verbose=%v\n\n",
+            method(config), config.Path, config.URLFile, config.SessionFile, config.NumConns, config.Duration,
+            config.Rate, config.Concurrency, config.Verbose)
     }
 }
@@ -1,10 +1,17 @@
 package perf
 
 import (
+    "context"
+    "encoding/pem"
     "fmt"
     . "github.com/jmervine/GoT"
+    "github.com/jmervine/goperf/results"
     "io/ioutil"
+    "net"
     "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
     "strings"
     "testing"
     "time"
@@ -26,7 +33,7 @@ func TestVersion(T *testing.T) {
 }
 
 func TestQuickRun(T *testing.T) {
-    go stubServer()
+    stubServer()
 
     rs := QuickRun("http://localhost:9876", 5, 5)
 
@@ -36,7 +43,7 @@ func TestQuickRun(T *testing.T) {
 }
 
 func TestSiege(T *testing.T) {
-    go stubServer()
+    stubServer()
 
     rs := Siege("http://localhost:9876", 5)
     Go(T).AssertLength(rs.Took, 5)
@@ -45,7 +52,7 @@ func TestSiege(T *testing.T) {
 }
 
 func TestStart(T *testing.T) {
-    go stubServer()
+    stubServer()
 
     rs := Start(newConf())
 
@@ -55,7 +62,7 @@ func TestStart(T *testing.T) {
 }
 
 func TestParallel(T *testing.T) {
-    go stubServer()
+    stubServer()
 
     rs := Parallel(newConf())
 
@@ -65,7 +72,7 @@ func TestParallel(T *testing.T) {
 }
 
 func TestSeries(T *testing.T) {
-    go stubServer()
+    stubServer()
 
     rs := Series(newConf())
 
@@ -74,14 +81,386 @@ func TestSeries(T *testing.T) {
     Go(T).AssertLength(rs.Errors, 0)
 }
 
+func TestDuration(T *testing.T) {
+    stubServer()
+
+    config := newConf()
+    config.NumConns = 0
+    config.Duration = 500 * time.Millisecond
+
+    rs := Start(config)
+    Go(T).RefuteEqual(rs.Requested, 0)
+    Go(T).AssertLength(rs.Took, rs.Requested)
+    Go(T).AssertLength(rs.Errors, 0)
+
+    // Unpaced parallel runs cannot be timed.
+    config.Rate = 0
+    _, err := TryParallel(config)
+    verr, ok := err.(*ValidationError)
+    Go(T).Assert(ok)
+    Go(T).AssertEqual(verr.Field, "Rate")
+}
+
+func TestTryStart(T *testing.T) {
+    stubServer()
+
+    rs, err := TryStart(newConf())
+    Go(T).Assert(err == nil)
+    Go(T).AssertLength(rs.Took, 5)
+
+    _, err = TryStart(&Configurator{NumConns: 5})
+    verr, ok := err.(*ValidationError)
+    Go(T).Assert(ok)
+    Go(T).AssertEqual(verr.Field, "Path")
+
+    _, err = TryStart(&Configurator{Path: "http://localhost:9876"})
+    verr, ok = err.(*ValidationError)
+    Go(T).Assert(ok)
+    Go(T).AssertEqual(verr.Field, "NumConns")
+
+    _, err = TryParallel(&Configurator{Path: "http://localhost:9876", NumConns: 1,
+        Headers: []string{"bad header"}})
+    verr, ok = err.(*ValidationError)
+    Go(T).Assert(ok)
+    Go(T).AssertEqual(verr.Field, "Headers")
+
+    _, err = TrySeries(&Configurator{Path: "http://localhost:9876", NumConns: 1,
+        BodyFile: "does/not/exist"})
+    verr, ok = err.(*ValidationError)
+    Go(T).Assert(ok)
+    Go(T).AssertEqual(verr.Field, "BodyFile")
+
+    _, err = TryQuickRun("http://%zz", 1, 0)
+    verr, ok = err.(*ValidationError)
+    Go(T).Assert(ok)
+    Go(T).AssertEqual(verr.Field, "Path")
+
+    _, err = TrySiege("ftp://localhost", 1)
+    Go(T).Refute(err == nil)
+
+    _, err = TryConnect("http://", false)
+    Go(T).Refute(err == nil)
+}
+
+func TestStartContext(T *testing.T) {
+    stubServer()
+
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+
+    rs, err := StartContext(ctx, newConf())
+    Go(T).Assert(err == nil)
+    Go(T).Assert(rs.Interrupted)
+    Go(T).AssertEqual(rs.Requested, 0)
+
+    rs, err = SeriesContext(context.Background(), newConf())
+    Go(T).Assert(err == nil)
+    Go(T).Refute(rs.Interrupted)
+    Go(T).AssertEqual(rs.Requested, 5)
+
+    _, err = ParallelContext(context.Background(), &Configurator{})
+    Go(T).Refute(err == nil)
+}
+
+func TestURLFile(T *testing.T) {
+    stubServer()
+
+    dir, _ := ioutil.TempDir("", "goperf")
+    defer os.RemoveAll(dir)
+
+    file := filepath.Join(dir, "urls.txt")
+    ioutil.WriteFile(file, []byte("http://localhost:9876/a\n/b\n"), 0644)
+
+    rs, err := TrySeries(&Configurator{URLFile: file, NumConns: 4})
+    Go(T).Assert(err == nil)
+    Go(T).AssertLength(rs.Targets, 2)
+    Go(T).AssertEqual(rs.Targets["GET http://localhost:9876/b"].Replies, 2)
+
+    _, err = TrySeries(&Configurator{URLFile: file, NumConns: 4, Selection: "bogus"})
+    Go(T).Refute(err == nil)
+
+    _, err = TrySeries(&Configurator{URLFile: filepath.Join(dir, "missing"), NumConns: 4})
+    verr, ok := err.(*ValidationError)
+    Go(T).Assert(ok)
+    Go(T).AssertEqual(verr.Field, "URLFile")
+}
+
+func TestSessionFile(T *testing.T) {
+    stubServer()
+
+    dir, _ := ioutil.TempDir("", "goperf")
+    defer os.RemoveAll(dir)
+
+    file := filepath.Join(dir, "sessions.txt")
+    ioutil.WriteFile(file, []byte("http://localhost:9876/a think=0.01\n  /b\n/c\n\n/d\n"), 0644)
+
+    rs, err := TryStart(&Configurator{SessionFile: file, NumConns: 4, Rate: 50})
+    Go(T).Assert(err == nil)
+    Go(T).AssertEqual(rs.Sessions, 4)
+    Go(T).AssertEqual(rs.SessionsCompleted, 4)
+    Go(T).AssertEqual(rs.Requested, 8)
+    Go(T).AssertEqual(rs.Targets["GET http://localhost:9876/b"].Replies, 2)
+
+    _, err = TryStart(&Configurator{SessionFile: file, URLFile: file, NumConns: 4})
+    Go(T).Refute(err == nil)
+
+    _, err = TryStart(&Configurator{SessionFile: filepath.Join(dir, "missing"), NumConns: 4})
+    verr, ok := err.(*ValidationError)
+    Go(T).Assert(ok)
+    Go(T).AssertEqual(verr.Field, "SessionFile")
+}
+
+func TestStartPanics(T *testing.T) {
+    defer func() {
+        _, ok := recover().(*ValidationError)
+        Go(T).Assert(ok)
+    }()
+
+    Start(&Configurator{})
+}
+
 func TestConnect(T *testing.T) {
-    go stubServer()
+    stubServer()
 
     r := Connect("http://localhost:9876", false)
 
     Go(T).AssertEqual(r.Code, 200)
 }
 
+func TestSetupRequest(T *testing.T) {
+    config := newConf()
+    config.Headers = []string{"Content-Type: application/json"}
+    config.Body = `{"hello":"web"}`
+
+    conn, _ := setup(config)
+    Go(T).AssertEqual(conn.Method, "POST")
+    Go(T).AssertEqual(conn.Header.Get("Content-Type"), "application/json")
+    Go(T).AssertEqual(string(conn.Body), `{"hello":"web"}`)
+
+    config.Method = "put"
+    conn, _ = setup(config)
+    Go(T).AssertEqual(conn.Method, "PUT")
+
+    config = newConf()
+    conn, _ = setup(config)
+    Go(T).AssertEqual(conn.Method, "GET")
+    Go(T).AssertLength(conn.Body, 0)
+    Go(T).Refute(conn.Cookies)
+
+    config.Cookies = true
+    conn, _ = setup(config)
+    Go(T).Assert(conn.Cookies)
+}
+
+func TestSetupQuiet(T *testing.T) {
+    config := newConf()
+    conn, _ := setup(config)
+    Go(T).Assert(conn.Messages == nil)
+
+    // Verbose messaging keeps out of JSON on stdout.
+    config.Quiet = true
+    config.Verbose = true
+    conn, _ = setup(config)
+    Go(T).Assert(conn.Messages == os.Stderr)
+}
+
+func TestSetupCheck(T *testing.T) {
+    config := newConf()
+    conn, _ := setup(config)
+    Go(T).Assert(conn.Check == nil)
+
+    config.ExpectStatus = []int{200}
+    config.ExpectPattern = `^<html`
+    conn, _ = setup(config)
+    Go(T).AssertEqual(conn.Check.Status[0], 200)
+    Go(T).AssertEqual(conn.Check.Pattern.String(), `^<html`)
+
+    config.ExpectPattern = `(`
+    _, err := setup(config)
+    verr, ok := err.(*ValidationError)
+    Go(T).Assert(ok)
+    Go(T).AssertEqual(verr.Field, "ExpectPattern")
+
+    config = newConf()
+    config.MaxSize = -1
+    _, err = setup(config)
+    Go(T).Refute(err == nil)
+}
+
+func TestSetupTLS(T *testing.T) {
+    server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprint(w, "secure")
+    }))
+    defer server.Close()
+
+    dir, _ := ioutil.TempDir("", "goperf")
+    defer os.RemoveAll(dir)
+
+    ca := filepath.Join(dir, "ca.pem")
+    ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644)
+
+    conn, _ := setup(newConf())
+    Go(T).Assert(conn.TLS == nil)
+
+    rs, err := TrySeries(&Configurator{Path: server.URL, NumConns: 2, CAFile: ca, TLSMax: "1.2",
+        Ciphers: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}})
+    Go(T).Assert(err == nil)
+    Go(T).AssertEqual(rs.TLSVersions["TLS 1.2"], 2)
+    Go(T).AssertEqual(rs.TLSCiphers["TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"], 2)
+
+    rs, _ = TrySeries(&Configurator{Path: server.URL, NumConns: 1, Insecure: true})
+    Go(T).AssertEqual(rs.ErrorsTotal, 0)
+
+    for field, bad := range map[string]*Configurator{
+        "CAFile":   {CAFile: filepath.Join(dir, "missing.pem")},
+        "CertFile": {CertFile: ca},
+        "TLSMin":   {TLSMin: "2.0"},
+        "TLSMax":   {TLSMin: "1.3", TLSMax: "1.2"},
+        "Ciphers":  {Ciphers: []string{"TLS_BOGUS"}},
+    } {
+        bad.Path, bad.NumConns = server.URL, 1
+        _, err = setup(bad)
+        verr, ok := err.(*ValidationError)
+        Go(T).Assert(ok, field)
+        Go(T).AssertEqual(verr.Field, field)
+    }
+}
+
+func TestSetupProtocol(T *testing.T) {
+    config := newConf()
+    config.Protocol = "h2c"
+    config.ConnsPerHost = 4
+    conn, err := setup(config)
+    Go(T).Assert(err == nil)
+    Go(T).AssertEqual(conn.Protocol, "h2c")
+    Go(T).AssertEqual(conn.ConnsPerHost, 4)
+
+    config.Protocol = "spdy"
+    _, err = setup(config)
+    verr, ok := err.(*ValidationError)
+    Go(T).Assert(ok)
+    Go(T).AssertEqual(verr.Field, "Protocol")
+
+    // h2 needs https, and h2c http.
+    config.Protocol = "h2"
+    _, err = setup(config)
+    verr, ok = err.(*ValidationError)
+    Go(T).Assert(ok)
+    Go(T).AssertEqual(verr.Field, "Protocol")
+
+    config.Path = "https://localhost:9876"
+    _, err = setup(config)
+    Go(T).Assert(err == nil)
+
+    config.Protocol = "h2c"
+    _, err = setup(config)
+    Go(T).Refute(err == nil)
+
+    dir, _ := ioutil.TempDir("", "goperf")
+    defer os.RemoveAll(dir)
+
+    file := filepath.Join(dir, "urls.txt")
+    ioutil.WriteFile(file, []byte("/a\nhttps://localhost:9876/b\n"), 0644)
+
+    config.Path = "http://localhost:9876"
+    config.URLFile = file
+    _, err = setup(config)
+    verr, ok = err.(*ValidationError)
+    Go(T).Assert(ok)
+    Go(T).AssertEqual(verr.Field, "Protocol")
+}
+
+func TestSetupArrival(T *testing.T) {
+    config := newConf()
+    conn, err := setup(config)
+    Go(T).Assert(err == nil)
+    Go(T).Assert(conn.Arrival == nil)
+
+    // Seeded arrivals repeat.
+    config.Arrival = "poisson"
+    config.Seed = 7
+    conn, err = setup(config)
+    Go(T).Assert(err == nil)
+    Go(T).AssertEqual(conn.Seed, int64(7))
+
+    again, _ := setup(config)
+    for i := 0; i < 10; i++ {
+        Go(T).AssertEqual(conn.Arrival.Next(), again.Arrival.Next())
+    }
+
+    config.Arrival = "burst:0"
+    _, err = setup(config)
+    verr, ok := err.(*ValidationError)
+    Go(T).Assert(ok)
+    Go(T).AssertEqual(verr.Field, "Arrival")
+}
+
+func TestSetupConsume(T *testing.T) {
+    config := newConf()
+    config.Consume = "hash"
+    conn, err := setup(config)
+    Go(T).Assert(err == nil)
+    Go(T).AssertEqual(conn.Consume, "hash")
+
+    config.Consume = "keep"
+    config.KeepBytes = 1024
+    conn, _ = setup(config)
+    Go(T).AssertEqual(conn.KeepBytes, int64(1024))
+
+    for _, bad := range []*Configurator{
+        {Path: "http://localhost", NumConns: 1, Consume: "bogus"},
+        {Path: "http://localhost", NumConns: 1, Consume: "discard", ExpectBody: "Welcome"},
+        {Path: "http://localhost", NumConns: 1, Consume: "keep", KeepBytes: -1},
+    } {
+        _, err = setup(bad)
+        Go(T).Refute(err == nil)
+    }
+}
+
+func TestSetupBuckets(T *testing.T) {
+    config := newConf()
+    config.Buckets = []float64{50, 10}
+    conn, _ := setup(config)
+    Go(T).AssertEqual(conn.Results.Histogram.Bounds[0], 10.0)
+
+    config.Buckets = []float64{10, 0}
+    _, err := setup(config)
+    verr, ok := err.(*ValidationError)
+    Go(T).Assert(ok)
+    Go(T).AssertEqual(verr.Field, "Buckets")
+}
+
+func TestSetupDiscard(T *testing.T) {
+    config := newConf()
+    config.Precision = 2
+    config.Discard = true
+    conn, _ := setup(config)
+    Go(T).AssertEqual(conn.Results.Latency.Precision(), 2)
+    Go(T).Assert(conn.Results.Discard)
+    Go(T).AssertEqual(cap(conn.Results.Took), 0)
+
+    config.Precision = 9
+    _, err := setup(config)
+    verr, ok := err.(*ValidationError)
+    Go(T).Assert(ok)
+    Go(T).AssertEqual(verr.Field, "Precision")
+}
+
+func TestSetupPercentiles(T *testing.T) {
+    config := newConf()
+    config.Percentiles = []float64{50, 99.9}
+    conn, _ := setup(config)
+    Go(T).AssertLength(conn.Results.Percentiles, 2)
+
+    for _, pct := range []float64{0, -1, 100.1} {
+        config.Percentiles = []float64{pct}
+        _, err := setup(config)
+        verr, ok := err.(*ValidationError)
+        Go(T).Assert(ok)
+        Go(T).AssertEqual(verr.Field, "Percentiles")
+    }
+}
+
 /***
  * Examples
  ******************************/
@@ -132,8 +511,16 @@ func ExampleSeries() {
     Display(results)
 }
 
+func ExampleDisplayComparison() {
+    old, _ := results.LoadReport("old.json")
+    new, _ := results.LoadReport("new.json")
+
+    comparison := results.Compare(old, new, 5)
+    DisplayComparison(comparison)
+}
+
 func ExampleConnect() {
-    go stubServer()
+    stubServer()
 
     results := Connect("http://localhost:9876", false)
     fmt.Printf("Status Code: %v\n", results.Code)
@@ -151,15 +538,20 @@ func stubServer() {
     }
 
     StubServerRunning = true
-    defer func() { StubServerRunning = false }()
 
     // Starting a stub server on :9876 to handle incoming requests
-    // for example.
+    // for example. The listener is opened before returning so that
+    // requests made right after never race the server start.
     http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
         time.Sleep(5 * time.Millisecond)
         fmt.Fprintln(w, "hello web")
     })
-    http.ListenAndServe(":9876", nil)
+
+    listener, err := net.Listen("tcp", ":9876")
+    if err != nil {
+        panic(err)
+    }
+    go http.Serve(listener, nil)
 }
 
 func newConf() *Configurator {
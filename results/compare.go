@@ -0,0 +1,128 @@
+package results
+
+import (
+    "encoding/json"
+    "io"
+    "math"
+    "os"
+    "strconv"
+)
+
+// Comparison is the difference between a baseline (old) and a new
+// Report, see Compare.
+type Comparison struct {
+    Tolerance float64
+    Deltas    []Delta
+}
+
+// Delta is the change in a single metric. Relative is in percent of Old,
+// and zero when Old is zero. Exceeded is set when the change is beyond
+// the tolerance, Regression when it is also for the worse (e.g. slower
+// times, lower rates or more errors).
+type Delta struct {
+    Metric     string
+    Old        float64
+    New        float64
+    Absolute   float64
+    Relative   float64
+    Exceeded   bool
+    Regression bool
+}
+
+// ReadReport decodes a JSON Report, as written by WriteJSON.
+func ReadReport(r io.Reader) (*Report, error) {
+    report := &Report{}
+    if err := json.NewDecoder(r).Decode(report); err != nil {
+        return nil, err
+    }
+    return report, nil
+}
+
+// LoadReport reads a JSON Report file, see ReadReport.
+func LoadReport(path string) (*Report, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    return ReadReport(file)
+}
+
+// Compare diffs request times (and corrected response times, when both
+// have them), rates and error counts between old and new Reports.
+// Changes of more than tolerance percent are flagged, as is any change
+// from zero.
+func Compare(old, new *Report, tolerance float64) *Comparison {
+    c := &Comparison{Tolerance: tolerance}
+
+    // Lower is better for times and errors, higher for rates.
+    c.stats("took", old.Took, new.Took)
+    if old.Response != nil && new.Response != nil {
+        c.stats("response", *old.Response, *new.Response)
+    }
+    c.add("conn_per_sec", old.ConnPerSec, new.ConnPerSec, true)
+    c.add("req_per_sec", old.ReqPerSec, new.ReqPerSec, true)
+
+    o, n := old.Errors, new.Errors
+    c.add("errors.total", float64(o.Total), float64(n.Total), false)
+    c.add("errors.conn_timeout", float64(o.ConnTimeout), float64(n.ConnTimeout), false)
+    c.add("errors.client_timeout", float64(o.ClientTimeout), float64(n.ClientTimeout), false)
+    c.add("errors.conn_refused", float64(o.ConnRefused), float64(n.ConnRefused), false)
+    c.add("errors.conn_reset", float64(o.ConnReset), float64(n.ConnReset), false)
+    c.add("errors.fd_unavail", float64(o.FdUnavail), float64(n.FdUnavail), false)
+    c.add("errors.addr_unavail", float64(o.AddrUnavail), float64(n.AddrUnavail), false)
+    c.add("errors.canceled", float64(o.Canceled), float64(n.Canceled), false)
+    c.add("errors.check_failed", float64(o.CheckFailed), float64(n.CheckFailed), false)
+    c.add("errors.other", float64(o.Other), float64(n.Other), false)
+
+    return c
+}
+
+// Regressions returns the Deltas changed for the worse beyond tolerance.
+func (c *Comparison) Regressions() []Delta {
+    var regressions []Delta
+    for _, d := range c.Deltas {
+        if d.Regression {
+            regressions = append(regressions, d)
+        }
+    }
+    return regressions
+}
+
+/**
+ * Private Methods
+ ******************************************/
+
+// stats adds avg, med and the percentiles reported by both.
+func (c *Comparison) stats(prefix string, old, new Stats) {
+    c.add(prefix+".avg", old.Avg, new.Avg, false)
+    c.add(prefix+".med", old.Med, new.Med, false)
+
+    for _, p := range old.Percentiles {
+        for _, q := range new.Percentiles {
+            if p.Pct == q.Pct {
+                c.add(prefix+".p"+strconv.FormatFloat(p.Pct, 'f', -1, 64), p.Value, q.Value, false)
+            }
+        }
+    }
+}
+
+func (c *Comparison) add(metric string, old, new float64, higherIsBetter bool) {
+    d := Delta{Metric: metric, Old: old, New: new, Absolute: new - old}
+
+    if old != 0 {
+        d.Relative = d.Absolute / math.Abs(old) * 100
+        d.Exceeded = math.Abs(d.Relative) > c.Tolerance
+    } else {
+        d.Exceeded = new != 0
+    }
+
+    worse := d.Absolute > 0
+    if higherIsBetter {
+        worse = d.Absolute < 0
+    }
+    d.Regression = d.Exceeded && worse
+
+    c.Deltas = append(c.Deltas, d)
+}
@@ -0,0 +1,86 @@
+package results
+
+import (
+    "bytes"
+    . "github.com/jmervine/GoT"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestCompare(T *testing.T) {
+    old := &Report{ReqPerSec: 100, ConnPerSec: 100, Took: Stats{Avg: 10, Med: 10,
+        Percentiles: []Percentile{{Pct: 99, Value: 20}, {Pct: 99.9, Value: 25}}}}
+    new := &Report{ReqPerSec: 80, ConnPerSec: 104, Took: Stats{Avg: 8, Med: 10.5,
+        Percentiles: []Percentile{{Pct: 99, Value: 30}}}}
+    new.Errors.Total = 2
+
+    c := Compare(old, new, 10)
+    deltas := map[string]Delta{}
+    for _, d := range c.Deltas {
+        deltas[d.Metric] = d
+    }
+
+    Go(T).AssertEqual(deltas["took.p99"].Absolute, 10.0)
+    Go(T).AssertEqual(deltas["took.p99"].Relative, 50.0)
+    Go(T).Assert(deltas["took.p99"].Regression)
+
+    // Only percentiles reported by both are compared.
+    _, ok := deltas["took.p99.9"]
+    Go(T).Refute(ok)
+
+    // Within tolerance.
+    Go(T).Refute(deltas["took.med"].Exceeded)
+    Go(T).Refute(deltas["conn_per_sec"].Exceeded)
+
+    // Beyond tolerance, but better.
+    Go(T).Assert(deltas["took.avg"].Exceeded)
+    Go(T).Refute(deltas["took.avg"].Regression)
+
+    Go(T).Assert(deltas["req_per_sec"].Regression)
+    Go(T).Assert(deltas["errors.total"].Regression)
+    Go(T).AssertEqual(deltas["errors.total"].Relative, 0.0)
+    Go(T).Refute(deltas["errors.other"].Exceeded)
+
+    Go(T).AssertLength(c.Regressions(), 3)
+}
+
+func TestCompareResponse(T *testing.T) {
+    old := &Report{Response: &Stats{Avg: 10, Med: 10, Percentiles: []Percentile{{Pct: 99, Value: 20}}}}
+    new := &Report{Response: &Stats{Avg: 10, Med: 10, Percentiles: []Percentile{{Pct: 99, Value: 40}}}}
+
+    c := Compare(old, new, 5)
+    Go(T).AssertLength(c.Regressions(), 1)
+    Go(T).AssertEqual(c.Regressions()[0].Metric, "response.p99")
+
+    // Only compared when both have them.
+    new.Response = nil
+    for _, d := range Compare(old, new, 5).Deltas {
+        Go(T).Refute(d.Metric == "response.avg")
+    }
+}
+
+func TestReadReport(T *testing.T) {
+    r := populatedRS(5)
+    r.Requested = 5
+    r.Finalize()
+
+    buf := &bytes.Buffer{}
+    r.WriteJSON(buf, false)
+
+    dir, _ := ioutil.TempDir("", "goperf")
+    defer os.RemoveAll(dir)
+    ioutil.WriteFile(filepath.Join(dir, "old.json"), buf.Bytes(), 0644)
+
+    report, err := LoadReport(filepath.Join(dir, "old.json"))
+    Go(T).Assert(err == nil)
+    Go(T).AssertEqual(report.Requested, 5)
+    Go(T).AssertEqual(report.Took.Max, 300.0)
+
+    _, err = ReadReport(bytes.NewBufferString("not json"))
+    Go(T).Refute(err == nil)
+
+    _, err = LoadReport(filepath.Join(dir, "missing.json"))
+    Go(T).Refute(err == nil)
+}
@@ -0,0 +1,118 @@
+package results
+
+import (
+    "fmt"
+    "io"
+    "math"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// DefaultBuckets are log-linear bucket bounds from 0.1ms to 60s, see
+// LogLinearBuckets.
+var DefaultBuckets = LogLinearBuckets(0.1, 60000, 9)
+
+// Histogram counts samples, in ms, into buckets. Counts[i] holds samples
+// at or below Bounds[i] (and above Bounds[i-1]), with a final overflow
+// bucket for samples above the last bound.
+type Histogram struct {
+    Bounds []float64 `json:"bounds"`
+    Counts []int64   `json:"counts"`
+    Total  int64     `json:"total"`
+    Min    float64   `json:"min"`
+    Max    float64   `json:"max"`
+    Sum    float64   `json:"sum"`
+}
+
+// NewHistogram creates a Histogram with bounds, DefaultBuckets when
+// empty. Bounds are sorted.
+func NewHistogram(bounds []float64) *Histogram {
+    if len(bounds) == 0 {
+        bounds = DefaultBuckets
+    }
+
+    sorted := make([]float64, len(bounds))
+    copy(sorted, bounds)
+    sort.Float64s(sorted)
+
+    return &Histogram{
+        Bounds: sorted,
+        Counts: make([]int64, len(sorted)+1),
+    }
+}
+
+// LogLinearBuckets generates bounds from min up to at least max, with
+// steps linear buckets per power of ten, e.g. (1, 100, 9) gives 1, 2 ..
+// 10, 20 .. 100.
+func LogLinearBuckets(min, max float64, steps int) []float64 {
+    if min <= 0 || max < min || steps < 1 {
+        return nil
+    }
+
+    bounds := []float64{min}
+    for decade := min; decade < max; decade *= 10 {
+        for i := 1; i <= steps; i++ {
+            bound := decade * (1 + 9*float64(i)/float64(steps))
+            bounds = append(bounds, math.Round(bound*1e6)/1e6)
+        }
+    }
+    return bounds
+}
+
+// Record adds a sample to the Histogram.
+func (h *Histogram) Record(took float64) {
+    i := sort.SearchFloat64s(h.Bounds, took)
+    h.Counts[i]++
+
+    if h.Total == 0 || took < h.Min {
+        h.Min = took
+    }
+
+    if took > h.Max {
+        h.Max = took
+    }
+
+    h.Total++
+    h.Sum += took
+}
+
+// Render draws the Histogram as ASCII bars, up to width wide, skipping
+// empty buckets before the first and after the last sample.
+func (h *Histogram) Render(w io.Writer, width int) {
+    first, last := -1, -1
+    var most int64
+    for i, count := range h.Counts {
+        if count == 0 {
+            continue
+        }
+
+        if first == -1 {
+            first = i
+        }
+        last = i
+
+        if count > most {
+            most = count
+        }
+    }
+
+    for i := first; first != -1 && i <= last; i++ {
+        label := "> " + formatBound(h.Bounds[len(h.Bounds)-1])
+        if i < len(h.Bounds) {
+            label = "<= " + formatBound(h.Bounds[i])
+        }
+
+        bar := int(math.Ceil(float64(h.Counts[i]) / float64(most) * float64(width)))
+        line := fmt.Sprintf("%12s %8d %s", label, h.Counts[i], strings.Repeat("#", bar))
+        fmt.Fprintln(w, strings.TrimRight(line, " "))
+    }
+}
+
+/**
+ * Private Methods
+ ******************************************/
+
+func formatBound(bound float64) string {
+    return strconv.FormatFloat(bound, 'f', -1, 64)
+}
@@ -0,0 +1,68 @@
+package results
+
+import (
+    "bytes"
+    "testing"
+    . "github.com/jmervine/GoT"
+)
+
+func TestLogLinearBuckets(T *testing.T) {
+    bounds := LogLinearBuckets(1, 100, 9)
+    Go(T).AssertLength(bounds, 19)
+    Go(T).AssertEqual(bounds[0], 1.0)
+    Go(T).AssertEqual(bounds[1], 2.0)
+    Go(T).AssertEqual(bounds[9], 10.0)
+    Go(T).AssertEqual(bounds[10], 20.0)
+    Go(T).AssertEqual(bounds[18], 100.0)
+
+    Go(T).AssertLength(LogLinearBuckets(1, 100, 2), 5)
+    Go(T).AssertLength(LogLinearBuckets(0, 100, 9), 0)
+}
+
+func TestHistogram(T *testing.T) {
+    h := NewHistogram([]float64{100, 10, 50})
+    Go(T).AssertEqual(h.Bounds[0], 10.0)
+
+    for _, took := range []float64{5, 10, 11, 75, 500, 1000} {
+        h.Record(took)
+    }
+
+    Go(T).AssertEqual(h.Counts[0], int64(2))
+    Go(T).AssertEqual(h.Counts[1], int64(1))
+    Go(T).AssertEqual(h.Counts[2], int64(1))
+    Go(T).AssertEqual(h.Counts[3], int64(2))
+    Go(T).AssertEqual(h.Total, int64(6))
+    Go(T).AssertEqual(h.Min, 5.0)
+    Go(T).AssertEqual(h.Max, 1000.0)
+
+    Go(T).AssertLength(NewHistogram(nil).Bounds, len(DefaultBuckets))
+}
+
+func TestHistogramRender(T *testing.T) {
+    h := NewHistogram([]float64{1, 2, 5, 10})
+    h.Record(1.5)
+    h.Record(4)
+    h.Record(4.5)
+
+    buf := &bytes.Buffer{}
+    h.Render(buf, 10)
+    Go(T).AssertEqual(buf.String(), ""+
+        "        <= 2        1 #####\n"+
+        "        <= 5        2 ##########\n")
+
+    buf.Reset()
+    NewHistogram(nil).Render(buf, 10)
+    Go(T).AssertEqual(buf.String(), "")
+}
+
+func TestResultsHistogram(T *testing.T) {
+    r := populatedRS(3)
+    Go(T).AssertEqual(r.Histogram.Total, int64(3))
+
+    r = Results{Histogram: NewHistogram([]float64{150})}
+    r.Add(Result{Index: 0, Took: 100, Target: "GET /a"})
+    r.Add(Result{Index: 1, Took: 200, Target: "GET /a"})
+    Go(T).AssertEqual(r.Histogram.Counts[0], int64(1))
+    Go(T).AssertEqual(r.Targets["GET /a"].Histogram.Counts[1], int64(1))
+    Go(T).AssertEqual(r.Report(false).Histogram.Total, int64(2))
+}
@@ -0,0 +1,140 @@
+package results
+
+import (
+    "bufio"
+    "encoding/csv"
+    "encoding/json"
+    "io"
+    "strconv"
+    "time"
+)
+
+// Record is the log entry for a single request, keeping its timing,
+// status and error together. Target is set for multi-URL and session
+// runs, see Result.Target.
+type Record struct {
+    Index       int       `json:"index"`
+    Target      string    `json:"target,omitempty"`
+    Start       time.Time `json:"start"`
+    Took        float64   `json:"took"`
+    Code        int       `json:"code"`
+    HeaderBytes int64     `json:"header_bytes"`
+    BodyBytes   int64     `json:"body_bytes"`
+    Error       string    `json:"error,omitempty"`
+    Digest      string    `json:"digest,omitempty"`
+}
+
+// Writer streams Records as requests complete. Write errors are sticky,
+// being returned again by all later calls, including Flush.
+type Writer interface {
+    Write(record Record) error
+    Flush() error
+}
+
+// NewRecord generates a Record from a Result.
+func NewRecord(result Result) Record {
+    record := Record{
+        Index:       result.Index,
+        Target:      result.Target,
+        Start:       result.Start,
+        Took:        result.Took,
+        Code:        result.Code,
+        HeaderBytes: result.HeaderLength,
+        BodyBytes:   result.ContentLength,
+        Digest:      result.Digest,
+    }
+
+    if result.Error != nil {
+        record.Error = result.Error.Error()
+    }
+
+    return record
+}
+
+// NewCSVWriter creates a Writer emitting CSV, with a header row.
+func NewCSVWriter(w io.Writer) Writer {
+    return &csvWriter{w: csv.NewWriter(w)}
+}
+
+// NewJSONLWriter creates a Writer emitting JSON Lines, one Record per
+// line.
+func NewJSONLWriter(w io.Writer) Writer {
+    buf := bufio.NewWriter(w)
+    return &jsonlWriter{buf: buf, encoder: json.NewEncoder(buf)}
+}
+
+/**
+ * Private Types
+ ******************************************/
+
+var csvHeader = []string{
+    "index", "target", "start", "took", "code", "header_bytes", "body_bytes",
+    "error", "digest",
+}
+
+type csvWriter struct {
+    w      *csv.Writer
+    header bool
+    err    error
+}
+
+func (c *csvWriter) Write(record Record) error {
+    if c.err != nil {
+        return c.err
+    }
+
+    if !c.header {
+        c.header = true
+        c.err = c.w.Write(csvHeader)
+        if c.err != nil {
+            return c.err
+        }
+    }
+
+    c.err = c.w.Write([]string{
+        strconv.Itoa(record.Index),
+        record.Target,
+        record.Start.Format(time.RFC3339Nano),
+        strconv.FormatFloat(record.Took, 'f', -1, 64),
+        strconv.Itoa(record.Code),
+        strconv.FormatInt(record.HeaderBytes, 10),
+        strconv.FormatInt(record.BodyBytes, 10),
+        record.Error,
+        record.Digest,
+    })
+    return c.err
+}
+
+func (c *csvWriter) Flush() error {
+    if c.err != nil {
+        return c.err
+    }
+
+    c.w.Flush()
+    c.err = c.w.Error()
+    return c.err
+}
+
+type jsonlWriter struct {
+    buf     *bufio.Writer
+    encoder *json.Encoder
+    err     error
+}
+
+func (j *jsonlWriter) Write(record Record) error {
+    if j.err != nil {
+        return j.err
+    }
+
+    j.err = j.encoder.Encode(record)
+    return j.err
+}
+
+func (j *jsonlWriter) Flush() error {
+    if j.err != nil {
+        return j.err
+    }
+
+    j.err = j.buf.Flush()
+    return j.err
+}
@@ -0,0 +1,96 @@
+package results
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+    . "github.com/jmervine/GoT"
+)
+
+func TestNewRecord(T *testing.T) {
+    start := time.Now()
+    record := NewRecord(Result{
+        Index:         3,
+        Target:        "GET /page",
+        Start:         start,
+        Took:          12.5,
+        Code:          200,
+        HeaderLength:  100,
+        ContentLength: 50,
+        Digest:        "abc123",
+    })
+
+    Go(T).AssertEqual(record.Index, 3, "")
+    Go(T).AssertEqual(record.Target, "GET /page", "")
+    Go(T).Assert(record.Start.Equal(start))
+    Go(T).AssertEqual(record.Took, 12.5, "")
+    Go(T).AssertEqual(record.HeaderBytes, 100, "")
+    Go(T).AssertEqual(record.BodyBytes, 50, "")
+    Go(T).AssertEqual(record.Digest, "abc123", "")
+    Go(T).AssertEqual(record.Error, "", "")
+
+    record = NewRecord(Result{Error: errors.New("connection refused")})
+    Go(T).AssertEqual(record.Error, "connection refused", "")
+}
+
+func TestCSVWriter(T *testing.T) {
+    var buf bytes.Buffer
+    w := NewCSVWriter(&buf)
+
+    start := time.Date(2014, 1, 2, 3, 4, 5, 0, time.UTC)
+    w.Write(Record{Index: 0, Target: "GET /page", Start: start, Took: 1.5, Code: 200, HeaderBytes: 10, BodyBytes: 20})
+    w.Write(Record{Index: 1, Start: start, Took: 2, Error: "oops, failed"})
+    Go(T).Assert(w.Flush() == nil)
+
+    lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+    Go(T).AssertLength(lines, 3)
+    Go(T).AssertEqual(lines[0], "index,target,start,took,code,header_bytes,body_bytes,error,digest", "")
+    Go(T).AssertEqual(lines[1], "0,GET /page,2014-01-02T03:04:05Z,1.5,200,10,20,,", "")
+    Go(T).AssertEqual(lines[2], `1,,2014-01-02T03:04:05Z,2,0,0,0,"oops, failed",`, "")
+}
+
+func TestJSONLWriter(T *testing.T) {
+    var buf bytes.Buffer
+    w := NewJSONLWriter(&buf)
+
+    w.Write(Record{Index: 0, Target: "GET /page", Took: 1.5, Code: 200})
+    w.Write(Record{Index: 1, Took: 2, Error: "failed"})
+    Go(T).Assert(w.Flush() == nil)
+
+    lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+    Go(T).AssertLength(lines, 2)
+
+    var record Record
+    Go(T).Assert(json.Unmarshal([]byte(lines[0]), &record) == nil)
+    Go(T).AssertEqual(record.Target, "GET /page", "")
+
+    // Target is left out when unset.
+    Go(T).Refute(strings.Contains(lines[1], "target"))
+
+    record = Record{}
+    Go(T).Assert(json.Unmarshal([]byte(lines[1]), &record) == nil)
+    Go(T).AssertEqual(record.Index, 1, "")
+    Go(T).AssertEqual(record.Error, "failed", "")
+}
+
+func TestWriterStickyError(T *testing.T) {
+    w := NewJSONLWriter(failingWriter{})
+
+    w.Write(Record{Index: 0})
+    err := w.Flush()
+    Go(T).Refute(err == nil)
+    Go(T).AssertEqual(w.Write(Record{Index: 1}), err, "")
+}
+
+/***
+ * Helpers
+ ******************************/
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+    return 0, errors.New("disk full")
+}
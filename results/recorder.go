@@ -0,0 +1,275 @@
+package results
+
+import (
+    "encoding/json"
+    "fmt"
+    "math"
+    "math/bits"
+)
+
+// DefaultPrecision is the Recorder precision used by Results, in
+// significant figures.
+const DefaultPrecision = 3
+
+// Recorder range, values are recorded in µs units and clamped to
+// recorderHighest.
+const (
+    recorderUnits   = 1000      // per ms
+    recorderHighest = 3600000.0 // ms, i.e. 1h
+)
+
+// Recorder is an HDR histogram style latency recorder, keeping counts
+// for values in ms from 1µs to 1h in bounded memory, to precision
+// significant figures. Min, Max and Mean are exact, ValueAt is within
+// the precision (e.g. 0.1% at 3). Recorders of the same precision can
+// be merged, and are serialized sparsely as JSON.
+type Recorder struct {
+    precision int
+
+    halfCountMagnitude uint
+    halfCount          int64
+    subBucketMask      int64
+
+    counts []int64
+    total  int64
+    min    float64
+    max    float64
+    sum    float64
+}
+
+// NewRecorder creates a Recorder with precision of 1 to 5 significant
+// figures.
+func NewRecorder(precision int) (*Recorder, error) {
+    if precision < 1 || precision > 5 {
+        return nil, fmt.Errorf("precision must be 1 to 5 significant figures, got %d", precision)
+    }
+
+    // Sub-buckets, of which there are a power of two, must be able to
+    // tell apart values to precision.
+    largest := 2 * math.Pow10(precision)
+    magnitude := uint(math.Ceil(math.Log2(largest)))
+    subBucketCount := int64(1) << magnitude
+
+    r := &Recorder{
+        precision:          precision,
+        halfCountMagnitude: magnitude - 1,
+        halfCount:          subBucketCount / 2,
+        subBucketMask:      subBucketCount - 1,
+    }
+
+    // Each bucket doubles the range covered.
+    buckets := 1
+    for smallest := subBucketCount; smallest <= int64(recorderHighest*recorderUnits); smallest <<= 1 {
+        buckets++
+    }
+    r.counts = make([]int64, (buckets+1)*int(r.halfCount))
+
+    return r, nil
+}
+
+// Record adds a value, in ms.
+func (r *Recorder) Record(value float64) {
+    if r.total == 0 || value < r.min {
+        r.min = value
+    }
+
+    if value > r.max {
+        r.max = value
+    }
+
+    r.total++
+    r.sum += value
+    r.counts[r.index(value)]++
+}
+
+// Merge adds other's values to r, both must have the same precision.
+func (r *Recorder) Merge(other *Recorder) error {
+    if other.precision != r.precision {
+        return fmt.Errorf("cannot merge recorders of precision %d and %d", r.precision, other.precision)
+    }
+
+    if other.total == 0 {
+        return nil
+    }
+
+    for i, count := range other.counts {
+        r.counts[i] += count
+    }
+
+    if r.total == 0 || other.min < r.min {
+        r.min = other.min
+    }
+
+    if other.max > r.max {
+        r.max = other.max
+    }
+
+    r.total += other.total
+    r.sum += other.sum
+    return nil
+}
+
+// ValueAt returns the value at percentile pct, e.g. 99.9, in ms. It is
+// the highest value equivalent to the recorded one at the precision,
+// bounded by Min and Max.
+func (r *Recorder) ValueAt(pct float64) float64 {
+    if r.total == 0 || math.IsNaN(pct) {
+        return 0
+    }
+
+    target := int64(math.Ceil(pct / 100 * float64(r.total)))
+    if target < 1 {
+        target = 1
+    }
+
+    var seen int64
+    for i, count := range r.counts {
+        if seen += count; seen >= target {
+            return math.Max(r.min, math.Min(r.max, r.highest(i)))
+        }
+    }
+
+    return r.max
+}
+
+// Count returns the number of values recorded.
+func (r *Recorder) Count() int64 {
+    return r.total
+}
+
+// Min returns the lowest value recorded, in ms.
+func (r *Recorder) Min() float64 {
+    return r.min
+}
+
+// Max returns the highest value recorded, in ms.
+func (r *Recorder) Max() float64 {
+    return r.max
+}
+
+// Mean returns the average value recorded, in ms.
+func (r *Recorder) Mean() float64 {
+    if r.total == 0 {
+        return 0
+    }
+    return r.sum / float64(r.total)
+}
+
+// Stats summarizes the Recorder with percentiles pcts, or
+// DefaultPercentiles when none are given.
+func (r *Recorder) Stats(pcts ...float64) Stats {
+    if len(pcts) == 0 {
+        pcts = DefaultPercentiles
+    }
+
+    stats := Stats{
+        Count: int(r.total),
+        Min:   r.min,
+        Avg:   r.Mean(),
+        Max:   r.max,
+        Med:   r.ValueAt(50),
+    }
+
+    for _, pct := range pcts {
+        stats.Percentiles = append(stats.Percentiles, Percentile{Pct: pct, Value: r.ValueAt(pct)})
+    }
+
+    return stats
+}
+
+// Precision returns the Recorder's precision, in significant figures.
+func (r *Recorder) Precision() int {
+    return r.precision
+}
+
+// MarshalJSON encodes the Recorder with only non-zero counts, as pairs
+// of bucket index and count.
+func (r *Recorder) MarshalJSON() ([]byte, error) {
+    data := recorderJSON{
+        Precision: r.precision,
+        Total:     r.total,
+        Min:       r.min,
+        Max:       r.max,
+        Sum:       r.sum,
+        Counts:    [][2]int64{},
+    }
+
+    for i, count := range r.counts {
+        if count > 0 {
+            data.Counts = append(data.Counts, [2]int64{int64(i), count})
+        }
+    }
+
+    return json.Marshal(data)
+}
+
+// UnmarshalJSON decodes a Recorder encoded by MarshalJSON.
+func (r *Recorder) UnmarshalJSON(b []byte) error {
+    data := recorderJSON{}
+    if err := json.Unmarshal(b, &data); err != nil {
+        return err
+    }
+
+    decoded, err := NewRecorder(data.Precision)
+    if err != nil {
+        return err
+    }
+
+    for _, pair := range data.Counts {
+        if pair[0] < 0 || pair[0] >= int64(len(decoded.counts)) {
+            return fmt.Errorf("recorder bucket %d out of range", pair[0])
+        }
+        decoded.counts[pair[0]] = pair[1]
+    }
+
+    decoded.total = data.Total
+    decoded.min = data.Min
+    decoded.max = data.Max
+    decoded.sum = data.Sum
+
+    *r = *decoded
+    return nil
+}
+
+/**
+ * Private Methods
+ ******************************************/
+
+type recorderJSON struct {
+    Precision int        `json:"precision"`
+    Total     int64      `json:"total"`
+    Min       float64    `json:"min"`
+    Max       float64    `json:"max"`
+    Sum       float64    `json:"sum"`
+    Counts    [][2]int64 `json:"counts"`
+}
+
+// index returns the counts index for value, see HdrHistogram.
+func (r *Recorder) index(value float64) int {
+    units := int64(math.Round(math.Min(value, recorderHighest) * recorderUnits))
+    if units < 0 {
+        units = 0
+    }
+
+    bucket := bits.Len64(uint64(units|r.subBucketMask)) - int(r.halfCountMagnitude+1)
+    sub := units >> uint(bucket)
+    i := (bucket+1)<<r.halfCountMagnitude + int(sub-r.halfCount)
+
+    if i >= len(r.counts) {
+        i = len(r.counts) - 1
+    }
+    return i
+}
+
+// highest returns the highest value counted at index i, in ms.
+func (r *Recorder) highest(i int) float64 {
+    bucket := (i >> r.halfCountMagnitude) - 1
+    sub := int64(i)&(r.halfCount-1) + r.halfCount
+    if bucket < 0 {
+        sub -= r.halfCount
+        bucket = 0
+    }
+
+    lowest := sub << uint(bucket)
+    return float64(lowest+(int64(1)<<uint(bucket))-1) / recorderUnits
+}
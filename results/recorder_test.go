@@ -0,0 +1,106 @@
+package results
+
+import (
+    "encoding/json"
+    "fmt"
+    "math"
+    "testing"
+    . "github.com/jmervine/GoT"
+)
+
+func TestNewRecorder(T *testing.T) {
+    _, err := NewRecorder(0)
+    Go(T).Refute(err == nil)
+
+    _, err = NewRecorder(6)
+    Go(T).Refute(err == nil)
+
+    low, _ := NewRecorder(1)
+    high, _ := NewRecorder(5)
+    Go(T).Assert(len(low.counts) < len(high.counts))
+    Go(T).AssertEqual(high.Precision(), 5)
+}
+
+func TestRecorderValueAt(T *testing.T) {
+    r, _ := NewRecorder(3)
+    Go(T).AssertEqual(r.ValueAt(99), 0.0)
+
+    for i := 1; i <= 100000; i++ {
+        r.Record(float64(i) / 100)
+    }
+
+    Go(T).AssertEqual(r.Count(), int64(100000))
+    Go(T).AssertEqual(r.Min(), 0.01)
+    Go(T).AssertEqual(r.Max(), 1000.0)
+    Go(T).Assert(math.Abs(r.Mean()-500.005) < 1e-6)
+
+    for _, pct := range []float64{50, 90, 99, 99.9, 99.99, 99.999} {
+        expected := pct * 10
+        actual := r.ValueAt(pct)
+        Go(T).Assert(math.Abs(actual-expected)/expected < 0.001, fmt.Sprint(pct, actual))
+    }
+    Go(T).AssertEqual(r.ValueAt(100), 1000.0)
+    Go(T).AssertEqual(r.ValueAt(150), 1000.0)
+    Go(T).AssertEqual(r.ValueAt(math.NaN()), 0.0)
+
+    // Values out of range are clamped.
+    r.Record(-1)
+    r.Record(2 * recorderHighest)
+    Go(T).AssertEqual(r.Max(), 2*recorderHighest)
+    Go(T).Assert(math.Abs(r.ValueAt(100)-recorderHighest)/recorderHighest < 0.001, fmt.Sprint(r.ValueAt(100)))
+}
+
+func TestRecorderStats(T *testing.T) {
+    r, _ := NewRecorder(3)
+    for i := 1; i <= 100; i++ {
+        r.Record(float64(i))
+    }
+
+    stats := r.Stats(50, 99.9)
+    Go(T).AssertEqual(stats.Count, 100)
+    Go(T).AssertEqual(stats.Min, 1.0)
+    Go(T).AssertEqual(stats.Max, 100.0)
+    Go(T).AssertEqual(stats.Avg, 50.5)
+    Go(T).Assert(math.Abs(stats.Med-50) < 0.1)
+    Go(T).AssertLength(stats.Percentiles, 2)
+    Go(T).AssertEqual(stats.Percentile(99.9), 100.0)
+
+    Go(T).AssertLength(r.Stats().Percentiles, len(DefaultPercentiles))
+}
+
+func TestRecorderMerge(T *testing.T) {
+    a, _ := NewRecorder(3)
+    b, _ := NewRecorder(3)
+    for i := 1; i <= 100; i++ {
+        a.Record(float64(i))
+        b.Record(float64(i + 100))
+    }
+
+    Go(T).Assert(a.Merge(b) == nil)
+    Go(T).AssertEqual(a.Count(), int64(200))
+    Go(T).AssertEqual(a.Min(), 1.0)
+    Go(T).AssertEqual(a.Max(), 200.0)
+    Go(T).Assert(math.Abs(a.ValueAt(50)-100) < 0.1)
+
+    c, _ := NewRecorder(2)
+    Go(T).Refute(a.Merge(c) == nil)
+}
+
+func TestRecorderJSON(T *testing.T) {
+    r, _ := NewRecorder(3)
+    for _, took := range []float64{1.5, 20, 300} {
+        r.Record(took)
+    }
+
+    b, err := json.Marshal(r)
+    Go(T).Assert(err == nil)
+
+    decoded := &Recorder{}
+    Go(T).Assert(json.Unmarshal(b, decoded) == nil)
+    Go(T).AssertEqual(decoded.Count(), int64(3))
+    Go(T).AssertEqual(decoded.Max(), 300.0)
+    Go(T).AssertEqual(decoded.ValueAt(50), r.ValueAt(50))
+
+    Go(T).Refute(json.Unmarshal([]byte(`{"precision":9}`), decoded) == nil)
+    Go(T).Refute(json.Unmarshal([]byte(`{"precision":3,"counts":[[-1,1]]}`), decoded) == nil)
+}
@@ -0,0 +1,237 @@
+package results
+
+import (
+    "encoding/json"
+    "io"
+)
+
+// Report is a serializable summary of Results, with stable field names
+// for use by scripts and dashboards.
+type Report struct {
+    Requested   int     `json:"requested"`
+    Replies     int     `json:"replies"`
+    Connections int     `json:"connections"`
+    Concurrency int     `json:"concurrency"`
+    TotalTime   float64 `json:"total_time"`
+    ConnPerSec  float64 `json:"conn_per_sec"`
+    ReqPerSec   float64 `json:"req_per_sec"`
+    KBPerSec    float64 `json:"kb_per_sec"`
+    ConnectTime float64 `json:"connect_time"`
+    Interrupted bool    `json:"interrupted"`
+
+    Took Stats `json:"took"`
+
+    Phases ReportPhases `json:"phases"`
+    Codes  ReportCodes  `json:"codes"`
+    Errors ReportErrors `json:"errors"`
+    Sizes  ReportSizes  `json:"sizes"`
+
+    // Response is Took corrected for coordinated omission, only set for
+    // Rate scheduled runs, see Results.Response.
+    Response *Stats `json:"response,omitempty"`
+
+    Histogram *Histogram `json:"histogram,omitempty"`
+
+    // Latency is mergeable across runs, see Recorder.
+    Latency *Recorder `json:"latency,omitempty"`
+
+    // Targets are per target breakdowns, for multi-URL workloads.
+    Targets map[string]*Report `json:"targets,omitempty"`
+
+    // Protocols count replies by protocol, and Streams requests per
+    // connection, only set when connections are reused.
+    Protocols map[string]int `json:"protocols,omitempty"`
+    Streams   *Sizes         `json:"streams,omitempty"`
+
+    // TLS is only set for TLS workloads.
+    TLS *ReportTLS `json:"tls,omitempty"`
+
+    // Sessions is only set for session workloads.
+    Sessions *ReportSessions `json:"sessions,omitempty"`
+
+    // Raw samples are only included when requested.
+    RawTook []float64 `json:"raw_took,omitempty"`
+    RawCode []int     `json:"raw_code,omitempty"`
+}
+
+// ReportPhases is the per phase section of a Report.
+type ReportPhases struct {
+    DNS      Stats `json:"dns"`
+    Connect  Stats `json:"connect"`
+    TLS      Stats `json:"tls"`
+    TTFB     Stats `json:"ttfb"`
+    Transfer Stats `json:"transfer"`
+}
+
+// ReportCodes is the status class section of a Report.
+type ReportCodes struct {
+    Code1xx int `json:"1xx"`
+    Code2xx int `json:"2xx"`
+    Code3xx int `json:"3xx"`
+    Code4xx int `json:"4xx"`
+    Code5xx int `json:"5xx"`
+}
+
+// ReportErrors is the error count section of a Report.
+type ReportErrors struct {
+    Total         int `json:"total"`
+    ConnTimeout   int `json:"conn_timeout"`
+    ClientTimeout int `json:"client_timeout"`
+    ConnRefused   int `json:"conn_refused"`
+    ConnReset     int `json:"conn_reset"`
+    FdUnavail     int `json:"fd_unavail"`
+    AddrUnavail   int `json:"addr_unavail"`
+    Canceled      int `json:"canceled"`
+    CheckFailed   int `json:"check_failed"`
+    Other         int `json:"other"`
+}
+
+// ReportSessions is the session section of a Report.
+type ReportSessions struct {
+    Started   int     `json:"started"`
+    Completed int     `json:"completed"`
+    Failed    int     `json:"failed"`
+    PerSec    float64 `json:"per_sec"`
+    Took      Stats   `json:"took"`
+}
+
+// ReportTLS is the TLS section of a Report, counting replies by
+// negotiated version and cipher suite, and on resumed sessions.
+type ReportTLS struct {
+    Versions map[string]int `json:"versions"`
+    Ciphers  map[string]int `json:"ciphers"`
+    Resumed  int            `json:"resumed"`
+}
+
+// ReportSizes is the reply size section of a Report, in bytes, summed
+// over the run and summarized per Reply. HeaderEstimated counts replies
+// whose header size is estimated, see Results.HeaderEstimated.
+type ReportSizes struct {
+    Content         int64            `json:"content"`
+    Header          int64            `json:"header"`
+    Total           int64            `json:"total"`
+    HeaderEstimated int              `json:"header_estimated"`
+    Reply           ReportReplySizes `json:"reply"`
+}
+
+// ReportReplySizes is the per reply part of ReportSizes.
+type ReportReplySizes struct {
+    Content Sizes `json:"content"`
+    Header  Sizes `json:"header"`
+    Total   Sizes `json:"total"`
+}
+
+// Report builds a Report from finalized Results, including raw Took
+// and Code samples when raw is true.
+func (res *Results) Report(raw bool) *Report {
+    report := &Report{
+        Requested:   res.Requested,
+        Replies:     res.Replies,
+        Connections: res.Connections,
+        Concurrency: res.Concurrency,
+        TotalTime:   res.TotalTime,
+        ConnPerSec:  res.ConnPerSec,
+        ReqPerSec:   res.ReqPerSec,
+        KBPerSec:    res.KBPerSec,
+        ConnectTime: res.ConnectTime,
+        Interrupted: res.Interrupted,
+
+        Took: res.TookStats(),
+
+        Phases: ReportPhases{
+            DNS:      res.DNS,
+            Connect:  res.Connect,
+            TLS:      res.TLS,
+            TTFB:     res.TTFB,
+            Transfer: res.Transfer,
+        },
+
+        Codes: ReportCodes{
+            Code1xx: res.Code1xx,
+            Code2xx: res.Code2xx,
+            Code3xx: res.Code3xx,
+            Code4xx: res.Code4xx,
+            Code5xx: res.Code5xx,
+        },
+
+        Errors: ReportErrors{
+            Total:         res.ErrorsTotal,
+            ConnTimeout:   res.ErrorsConnTimeout,
+            ClientTimeout: res.ErrorsClientTimeout,
+            ConnRefused:   res.ErrorsConnRefused,
+            ConnReset:     res.ErrorsConnReset,
+            FdUnavail:     res.ErrorsFdUnavail,
+            AddrUnavail:   res.ErrorsAddrUnavail,
+            Canceled:      res.ErrorsCanceled,
+            CheckFailed:   res.ErrorsCheckFailed,
+            Other:         res.ErrorsOther,
+        },
+
+        Sizes: ReportSizes{
+            Content: res.ContentLength,
+            Header:  res.HeaderLength,
+            Total:   res.TotalLength,
+
+            HeaderEstimated: res.HeaderEstimated,
+
+            Reply: ReportReplySizes{
+                Content: res.ContentSize,
+                Header:  res.HeaderSize,
+                Total:   res.TotalSize,
+            },
+        },
+
+        Histogram: res.Histogram,
+        Latency:   res.Latency,
+    }
+
+    if res.Response.Count > 0 {
+        response := res.Response
+        report.Response = &response
+    }
+
+    for name, target := range res.Targets {
+        if report.Targets == nil {
+            report.Targets = make(map[string]*Report)
+        }
+        report.Targets[name] = target.Report(false)
+    }
+
+    report.Protocols = res.Protocols
+    if res.StreamsPerConn.Count > 0 {
+        streams := res.StreamsPerConn
+        report.Streams = &streams
+    }
+
+    if len(res.TLSVersions) > 0 {
+        report.TLS = &ReportTLS{
+            Versions: res.TLSVersions,
+            Ciphers:  res.TLSCiphers,
+            Resumed:  res.TLSResumed,
+        }
+    }
+
+    if res.Sessions > 0 {
+        report.Sessions = &ReportSessions{
+            Started:   res.Sessions,
+            Completed: res.SessionsCompleted,
+            Failed:    res.SessionsFailed,
+            PerSec:    res.SessionPerSec,
+            Took:      res.Session,
+        }
+    }
+
+    if raw {
+        report.RawTook = res.Took
+        report.RawCode = res.Code
+    }
+
+    return report
+}
+
+// WriteJSON writes finalized Results to w as an indented JSON Report.
+func (res *Results) WriteJSON(w io.Writer, raw bool) error {
+    encoder := json.NewEncoder(w)
+    encoder.SetIndent("", "  ")
+    return encoder.Encode(res.Report(raw))
+}
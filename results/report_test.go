@@ -0,0 +1,103 @@
+package results
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "testing"
+    "time"
+    . "github.com/jmervine/GoT"
+)
+
+func TestReport(T *testing.T) {
+    r := populatedRS(5)
+    r.Requested = 5
+    r.Finalize()
+
+    report := r.Report(false)
+    Go(T).AssertEqual(report.Requested, 5, "")
+    Go(T).AssertEqual(report.Replies, 5, "")
+    Go(T).AssertEqual(report.Took.Min, 100.0, "")
+    Go(T).AssertEqual(report.Took.Max, 300.0, "")
+    Go(T).AssertEqual(report.Codes.Code2xx, 5, "")
+    Go(T).AssertLength(report.RawTook, 0)
+
+    report = r.Report(true)
+    Go(T).AssertLength(report.RawTook, 5)
+    Go(T).AssertLength(report.RawCode, 5)
+}
+
+func TestWriteJSON(T *testing.T) {
+    r := populatedRS(5)
+    r.Finalize()
+
+    var buf bytes.Buffer
+    err := r.WriteJSON(&buf, false)
+    Go(T).Assert(err == nil)
+
+    var decoded map[string]interface{}
+    err = json.Unmarshal(buf.Bytes(), &decoded)
+    Go(T).Assert(err == nil)
+
+    for _, key := range []string{"requested", "replies", "took", "phases", "codes", "errors", "sizes"} {
+        _, ok := decoded[key]
+        Go(T).Assert(ok, key)
+    }
+
+    _, ok := decoded["raw_took"]
+    Go(T).Refute(ok)
+
+    codes := decoded["codes"].(map[string]interface{})
+    Go(T).AssertEqual(codes["2xx"], 5.0, "")
+
+    took := decoded["took"].(map[string]interface{})
+    percentiles := took["percentiles"].([]interface{})
+    Go(T).AssertLength(percentiles, len(DefaultPercentiles))
+
+    p99 := percentiles[3].(map[string]interface{})
+    Go(T).AssertEqual(p99["pct"], 99.0, "")
+    Go(T).AssertEqual(p99["value"], 300.0, "")
+}
+
+func TestReportPercentiles(T *testing.T) {
+    r := populatedRS(5)
+    r.Percentiles = []float64{50, 99.9}
+    r.Finalize()
+
+    report := r.Report(false)
+    Go(T).AssertLength(report.Took.Percentiles, 2)
+    Go(T).AssertEqual(report.Took.Percentile(50), 200.0, "")
+    Go(T).AssertEqual(report.Took.Percentile(99.9), 300.0, "")
+    Go(T).AssertLength(report.Phases.TTFB.Percentiles, 2)
+}
+
+func TestReportResponse(T *testing.T) {
+    r := populatedRS(5)
+    r.Finalize()
+    report := r.Report(false)
+    Go(T).Assert(report.Response == nil)
+
+    start := time.Now()
+    r.Add(Result{Index: 5, Took: 100, Code: 200, Start: start.Add(time.Second), Intended: start})
+    r.Finalize()
+    report = r.Report(false)
+    Go(T).AssertEqual(report.Response.Count, 1, "")
+    Go(T).AssertEqual(report.Response.Max, 1100.0, "")
+}
+
+/***
+ * Examples
+ ******************************/
+
+func ExampleResults_WriteJSON() {
+    r := Results{}
+    r.Add(Result{Index: 0, Took: 300.0, Code: 200})
+    r.Finalize()
+
+    report := r.Report(false)
+    out, _ := json.Marshal(report.Codes)
+    fmt.Println(string(out))
+
+    // Output:
+    // {"1xx":0,"2xx":1,"3xx":0,"4xx":0,"5xx":0}
+}
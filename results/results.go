@@ -1,30 +1,59 @@
 package results
 
 import (
+    "context"
+    "errors"
     "math"
     "net"
-    "net/url"
     "sort"
     "strings"
+    "time"
 )
 
+// ErrCanceled wraps errors of requests aborted because their run was
+// cancelled, see Results.ErrorsCanceled.
+var ErrCanceled = errors.New("request canceled")
+
+// ErrCheckFailed wraps errors of responses failing validation, see
+// Results.ErrorsCheckFailed.
+var ErrCheckFailed = errors.New("check failed")
+
 // Results is a container for the performance test results.
 type Results struct {
     Requested   int
     Replies     int
+    Connections int
     ConnectTime float64
     TotalTime   float64
     ConnPerSec  float64
+    ReqPerSec   float64
+
+    // Concurrency is the number of workers used, zero for open-ended
+    // Parallel runs.
+    Concurrency int
+
+    // Interrupted is set when a run was cancelled before completing.
+    Interrupted bool
 
-    Took     []float64
-    TookMin  float64
-    TookMed  float64
-    TookAvg  float64
-    TookMax  float64
-    Took85th float64
-    Took90th float64
-    Took95th float64
-    Took99th float64
+    // Percentiles are those reported, e.g. 99.9, DefaultPercentiles
+    // when empty. See Percentile for others.
+    Percentiles []float64
+
+    Took    []float64
+    TookMin float64
+    TookMed float64
+    TookAvg float64
+    TookMax float64
+
+    // Latency records Took in bounded memory, for Percentile once
+    // samples are discarded and for merging runs. It is created with
+    // DefaultPrecision unless set before the first Add.
+    Latency *Recorder
+
+    // Discard drops raw Took, Code and Timings samples, and Errors, once
+    // recorded, bounding memory for long runs. Took stats then come from
+    // Latency, and phase stats and raw output are empty.
+    Discard bool
 
     Code    []int
     Code1xx int
@@ -33,18 +62,136 @@ type Results struct {
     Code4xx int
     Code5xx int
 
-    Errors            []error
-    ErrorsTotal       int
-    ErrorsConnTimeout int
-    ErrorsConnRefused int
-    ErrorsConnReset   int
-    ErrorsFdUnavail   int
-    ErrorsAddrUnavail int
-    ErrorsOther       int
+    Errors              []error
+    ErrorsTotal         int
+    ErrorsConnTimeout   int
+    ErrorsClientTimeout int
+    ErrorsConnRefused   int
+    ErrorsConnReset     int
+    ErrorsFdUnavail     int
+    ErrorsAddrUnavail   int
+    ErrorsCanceled      int
+    ErrorsCheckFailed   int
+    ErrorsOther         int
 
-    ContentLength int64
-    HeaderLength  int64
-    TotalLength   int64
+    // ContentLength, HeaderLength (including any trailer and chunk
+    // framing) and TotalLength are bytes received over the run, summed
+    // per reply. HeaderEstimated counts replies whose HeaderLength is
+    // estimated as HTTP/1.x text, see Result.HeaderEstimated, which
+    // overstates HTTP/2 replies. ContentSize, HeaderSize and TotalSize
+    // summarize them per reply, and KBPerSec is TotalLength (received
+    // only) over TotalTime.
+    ContentLength   int64
+    HeaderLength    int64
+    TotalLength     int64
+    ContentSize     Sizes
+    HeaderSize      Sizes
+    TotalSize       Sizes
+    KBPerSec        float64
+    HeaderEstimated int
+
+    // Timings are per request phase timings, indexed as Took. Each phase
+    // is summarized by Finalize, counting only requests where the phase
+    // occurred (e.g. no DNS for IP hosts, no Connect on reused
+    // connections).
+    Timings  []Timing
+    DNS      Stats
+    Connect  Stats
+    TLS      Stats
+    TTFB     Stats
+    Transfer Stats
+
+    // Response is Took corrected for coordinated omission, measured from
+    // when the Rate schedule intended to send each request rather than
+    // when it was sent, see Result.Intended. Took is then service time,
+    // Response the time a client on schedule would have waited. Only
+    // scheduled requests count, with samples in ResponseTook unless
+    // discarding, and in ResponseLatency.
+    ResponseTook    []float64
+    ResponseLatency *Recorder
+    Response        Stats
+
+    // TLSVersions and TLSCiphers count replies over TLS by negotiated
+    // version and cipher suite, TLSResumed those on resumed sessions.
+    TLSVersions map[string]int
+    TLSCiphers  map[string]int
+    TLSResumed  int
+
+    // Protocols counts replies by protocol, e.g. "HTTP/2.0". Streams
+    // counts requests per reused connection, keyed by Result.Conn, and
+    // is summarized by Finalize as StreamsPerConn.
+    Protocols      map[string]int
+    Streams        map[int]int
+    StreamsPerConn Sizes
+
+    // Histogram counts Took samples into buckets, DefaultBuckets unless
+    // set before the first Add.
+    Histogram *Histogram
+
+    // Targets breaks results down per target (method and URL) for
+    // multi-URL workloads, keyed by Result.Target.
+    Targets map[string]*Results
+
+    // sorted caches Took sorted, see sortedTook.
+    sorted []float64
+
+    // canceled holds the indexes of canceled requests, whose slots are
+    // dropped from Took, Code and Timings by Finalize.
+    canceled []int
+
+    // added counts results added, canceled or not, as target breakdowns
+    // have no requests count of their own.
+    added int
+
+    // Sessions counts sessions started in session workloads, of which
+    // SessionsCompleted ran every request and SessionsFailed stopped at
+    // a failed request. Sessions cut short by cancellation are neither.
+    // SessionTook holds the duration of completed sessions, in ms,
+    // summarized by Finalize as Session.
+    Sessions          int
+    SessionsCompleted int
+    SessionsFailed    int
+    SessionPerSec     float64
+    SessionTook       []float64
+    Session           Stats
+}
+
+// Timing is the phase breakdown of a single request, in ms. TTFB is
+// measured from the request being written, so phases sum to about Took.
+type Timing struct {
+    DNS      float64
+    Connect  float64
+    TLS      float64
+    TTFB     float64
+    Transfer float64
+}
+
+// DefaultPercentiles are reported unless Results.Percentiles is set.
+var DefaultPercentiles = []float64{85, 90, 95, 99}
+
+// Stats summarizes a set of samples, in ms.
+type Stats struct {
+    Count       int          `json:"count"`
+    Min         float64      `json:"min"`
+    Avg         float64      `json:"avg"`
+    Max         float64      `json:"max"`
+    Med         float64      `json:"med"`
+    Percentiles []Percentile `json:"percentiles"`
+}
+
+// Sizes summarizes sizes over Count items, e.g. reply sizes in bytes or
+// streams per connection.
+type Sizes struct {
+    Count int     `json:"count"`
+    Min   int64   `json:"min"`
+    Avg   float64 `json:"avg"`
+    Max   int64   `json:"max"`
+}
+
+// Percentile is the Value at percentile Pct, e.g. 99.9, in ms.
+type Percentile struct {
+    Pct   float64 `json:"pct"`
+    Value float64 `json:"value"`
 }
 
 /**
@@ -54,97 +201,326 @@ type Results struct {
 // Result is the performance test result transporter.
 type Result struct {
     Index, Code   int
+    Start         time.Time
     Took          float64
     Error         error
     TotalLength   int64
     ContentLength int64
     HeaderLength  int64
+    Timing        Timing
+    Target        string
+
+    // Intended is when the Rate schedule meant to send the request, zero
+    // for unscheduled requests.
+    Intended time.Time
+
+    // Digest is the hex SHA-256 of the body, when hashed.
+    Digest string
+
+    // HeaderEstimated is set when HeaderLength is estimated rather than
+    // counted on the connection, i.e. over TLS or HTTP/2.
+    HeaderEstimated bool
+
+    // TLSVersion and TLSCipher name the negotiated version and cipher
+    // suite on TLS connections, TLSResumed is set when the session was
+    // resumed.
+    TLSVersion string
+    TLSCipher  string
+    TLSResumed bool
+
+    // Proto is the response protocol, e.g. "HTTP/2.0", and Conn numbers
+    // the connection used, from 1, when connections are reused.
+    Proto string
+    Conn  int
 }
 
-// Add adds Result data to Results.
+// Response returns Took corrected for coordinated omission, in ms, i.e.
+// from Intended rather than Start, or Took when the request was not
+// scheduled or was sent early.
+func (result Result) Response() float64 {
+    if result.Intended.IsZero() || !result.Start.After(result.Intended) {
+        return result.Took
+    }
+    return result.Took + float64(result.Start.Sub(result.Intended))/float64(time.Millisecond)
+}
+
+// SessionResult is the session result transporter, Took being in ms.
+type SessionResult struct {
+    Took      float64
+    Requests  int
+    Completed bool
+    Failed    bool
+}
+
+// Add adds Result data to Results, growing Took and Code to fit
+// result.Index when needed. Canceled requests are only counted as
+// errors, their time to cancel being no measure of the server.
 func (res *Results) Add(result Result) {
-    res.Took[result.Index] = result.Took
-    res.Code[result.Index] = result.Code
+    res.added++
+
+    if res.Latency == nil {
+        res.Latency, _ = NewRecorder(DefaultPrecision)
+    }
+
+    if res.Histogram == nil {
+        res.Histogram = NewHistogram(nil)
+    }
+
+    if canceled(result.Error) {
+        res.addError(result.Error)
+        if !res.Discard && result.Index >= 0 {
+            res.canceled = append(res.canceled, result.Index)
+        }
+
+        if result.Target != "" {
+            res.addTarget(result)
+        }
+        return
+    }
+
+    if res.Discard {
+        res.countCode(result.Code)
+    } else {
+        res.grow(result.Index + 1)
+
+        res.Took[result.Index] = result.Took
+        res.Code[result.Index] = result.Code
+        res.Timings[result.Index] = result.Timing
+        res.sorted = nil
+    }
+
+    res.Latency.Record(result.Took)
+    res.Histogram.Record(result.Took)
+
+    if !result.Intended.IsZero() {
+        res.addResponse(result.Response())
+    }
+
+    if result.Target != "" {
+        res.addTarget(result)
+    }
 
     if result.Error != nil {
-        res.Errors = append(res.Errors, result.Error)
+        res.addError(result.Error)
+    }
+
+    if result.TLSVersion != "" {
+        res.addTLS(result)
+    }
+
+    if result.Proto != "" {
+        if res.Protocols == nil {
+            res.Protocols = make(map[string]int)
+        }
+        res.Protocols[result.Proto]++
+    }
+
+    if result.Conn > 0 {
+        if res.Streams == nil {
+            res.Streams = make(map[int]int)
+        }
+        res.Streams[result.Conn]++
     }
 
-    if res.TotalLength == 0 {
-        res.TotalLength = result.TotalLength
+    // Only replies have sizes.
+    if result.Code > 0 {
+        res.ContentLength += result.ContentLength
+        res.HeaderLength += result.HeaderLength
+        res.TotalLength += result.TotalLength
+        res.ContentSize.add(result.ContentLength)
+        res.HeaderSize.add(result.HeaderLength)
+        res.TotalSize.add(result.TotalLength)
+        if result.HeaderEstimated {
+            res.HeaderEstimated++
+        }
     }
+}
 
-    if res.ContentLength == 0 {
-        res.ContentLength = result.ContentLength
+// AddSession adds SessionResult data to Results.
+func (res *Results) AddSession(session SessionResult) {
+    res.Sessions++
+
+    if session.Completed {
+        res.SessionsCompleted++
+        res.SessionTook = append(res.SessionTook, session.Took)
+    } else if session.Failed {
+        res.SessionsFailed++
     }
+}
 
-    if res.HeaderLength == 0 {
-        res.HeaderLength = result.HeaderLength
+// Reset clears Results for a new run, keeping its settings, i.e.
+// Percentiles, Discard, the precision of Latency and bounds of
+// Histogram, and the capacity of Took and Code.
+func (res *Results) Reset() {
+    fresh := Results{
+        Percentiles: res.Percentiles,
+        Discard:     res.Discard,
     }
+
+    if res.Latency != nil {
+        fresh.Latency, _ = NewRecorder(res.Latency.Precision())
+    }
+
+    if res.Histogram != nil {
+        fresh.Histogram = NewHistogram(res.Histogram.Bounds)
+    }
+
+    if !res.Discard {
+        fresh.Took = make([]float64, 0, cap(res.Took))
+        fresh.Code = make([]int, 0, cap(res.Code))
+    }
+
+    *res = fresh
 }
 
-// Finalize finalizes results, generating min, max, avg med and percentiles.
+// Finalize finalizes results, generating min, max, avg and med, see
+// Percentile for percentiles.
 func (res *Results) Finalize() {
+    res.compact()
+    res.sorted = nil
     res.Replies = len(res.Took)
+    if res.Discard && res.Latency != nil {
+        res.Replies = int(res.Latency.Count())
+    }
+
     res.min()
     res.max()
     res.avg()
     res.med()
-    res.pct()
-
-    // Code counts
-    for _, code := range res.Code {
-        if code < 100 { // ignore
-        } else if code < 200 {
-            res.Code1xx++
-        } else if code < 300 {
-            res.Code2xx++
-        } else if code < 400 {
-            res.Code3xx++
-        } else if code < 500 {
-            res.Code4xx++
-        } else if code < 600 {
-            res.Code5xx++
-        }
-    }
-
-    // Error counts
-    res.ErrorsTotal = len(res.Errors)
-
-    for _, err := range res.Errors {
-        e := err.(*url.Error).Err.(*net.OpError).Error()
-        if strings.Contains(e, "connection refused") {
-            res.ErrorsConnRefused++
-        } else if strings.Contains(e, "connection reset") {
-            res.ErrorsConnReset++
-        } else if strings.Contains(e, "connection timed out") {
-            res.ErrorsConnTimeout++
-        } else if strings.Contains(e, "no free file descriptors") {
-            res.ErrorsFdUnavail++
-        } else if strings.Contains(e, "no such host") {
-            res.ErrorsAddrUnavail++
-        } else {
-            res.ErrorsOther++
+    res.phases()
+
+    // Code counts, kept as they are added when discarding samples.
+    if !res.Discard {
+        res.Code1xx, res.Code2xx, res.Code3xx, res.Code4xx, res.Code5xx = 0, 0, 0, 0, 0
+        for _, code := range res.Code {
+            res.countCode(code)
+        }
+    }
+
+    // Error counts, kept as they are added when discarding samples.
+    if !res.Discard {
+        res.ErrorsTotal = len(res.Errors)
+        res.ErrorsConnTimeout, res.ErrorsClientTimeout = 0, 0
+        res.ErrorsConnRefused, res.ErrorsConnReset = 0, 0
+        res.ErrorsFdUnavail, res.ErrorsAddrUnavail = 0, 0
+        res.ErrorsCanceled, res.ErrorsCheckFailed, res.ErrorsOther = 0, 0, 0
+
+        for _, err := range res.Errors {
+            res.countError(err)
+        }
+    }
+
+    res.Session = Summarize(res.SessionTook, res.percentiles()...)
+
+    var streams int64
+    res.StreamsPerConn = Sizes{}
+    for _, n := range res.Streams {
+        res.StreamsPerConn.add(int64(n))
+        streams += int64(n)
+    }
+    res.StreamsPerConn.finalize(streams)
+
+    res.ContentSize.finalize(res.ContentLength)
+    res.HeaderSize.finalize(res.HeaderLength)
+    res.TotalSize.finalize(res.TotalLength)
+    if res.TotalTime > 0 {
+        res.KBPerSec = float64(res.TotalLength) / 1024 / res.TotalTime
+    }
+
+    res.Response = Summarize(res.ResponseTook, res.percentiles()...)
+    if res.Discard && res.ResponseLatency != nil {
+        res.Response = res.ResponseLatency.Stats(res.percentiles()...)
+    }
+
+    // Target breakdowns share the run's totals.
+    for _, target := range res.Targets {
+        target.Requested = target.added
+        target.Concurrency = res.Concurrency
+        target.Interrupted = res.Interrupted
+        target.TotalTime = res.TotalTime
+        target.Percentiles = res.Percentiles
+        if res.TotalTime > 0 {
+            target.ReqPerSec = float64(target.Requested) / res.TotalTime
         }
+        target.Finalize()
     }
 }
 
 // CalculatePct calculates percentiles from existing Took values.
 func (res *Results) CalculatePct(pct int) float64 {
-    slice := res.copyTook()
+    return percentile(res.sortedTook(), float64(pct))
+}
 
-    l := len(slice)
-    switch l {
-    case 0:
-        return float64(0)
-    case 1:
-        return slice[0]
-    case 2:
-        return slice[1]
+// Percentile returns the pct percentile of Took, e.g. 99.9, exactly
+// from samples or, when discarding them, from Latency to its precision.
+func (res *Results) Percentile(pct float64) float64 {
+    if res.Discard {
+        if res.Latency == nil {
+            return 0
+        }
+        return res.Latency.ValueAt(pct)
     }
 
-    index := int(math.Floor(((float64(l)/100)*float64(pct))+0.5) - 1)
-    return slice[index]
+    return percentile(res.sortedTook(), pct)
+}
+
+// TookStats summarizes Took with the reported Percentiles.
+func (res *Results) TookStats() Stats {
+    stats := Stats{
+        Count: res.Replies,
+        Min:   res.TookMin,
+        Avg:   res.TookAvg,
+        Max:   res.TookMax,
+        Med:   res.TookMed,
+    }
+
+    for _, pct := range res.percentiles() {
+        stats.Percentiles = append(stats.Percentiles, Percentile{Pct: pct, Value: res.Percentile(pct)})
+    }
+
+    return stats
+}
+
+// Summarize generates Stats for samples, with percentiles pcts, or
+// DefaultPercentiles when none are given.
+func Summarize(samples []float64, pcts ...float64) Stats {
+    slice := make([]float64, len(samples))
+    copy(slice, samples)
+    sort.Float64s(slice)
+
+    if len(pcts) == 0 {
+        pcts = DefaultPercentiles
+    }
+
+    stats := Stats{Count: len(slice)}
+    for _, pct := range pcts {
+        stats.Percentiles = append(stats.Percentiles, Percentile{Pct: pct, Value: percentile(slice, pct)})
+    }
+
+    if len(slice) == 0 {
+        return stats
+    }
+
+    var total float64
+    for _, n := range slice {
+        total += n
+    }
+
+    stats.Min = slice[0]
+    stats.Max = slice[len(slice)-1]
+    stats.Avg = total / float64(len(slice))
+    stats.Med = median(slice)
+    return stats
+}
+
+// Percentile returns the value at pct, zero when not summarized.
+func (s Stats) Percentile(pct float64) float64 {
+    for _, p := range s.Percentiles {
+        if p.Pct == pct {
+            return p.Value
+        }
+    }
+    return 0
 }
 
 /**
@@ -152,68 +528,327 @@ func (res *Results) CalculatePct(pct int) float64 {
  ******************************************/
 
 func (res *Results) min() {
-    slice := res.copyTook()
+    if res.Discard {
+        if res.Latency != nil {
+            res.TookMin = res.Latency.Min()
+        }
+        return
+    }
 
-    if !sort.Float64sAreSorted(slice) {
-        sort.Float64s(slice)
+    slice := res.sortedTook()
+    if len(slice) == 0 {
+        return
     }
     res.TookMin = slice[0]
 }
 
 func (res *Results) max() {
-    slice := res.copyTook()
+    if res.Discard {
+        if res.Latency != nil {
+            res.TookMax = res.Latency.Max()
+        }
+        return
+    }
 
-    if !sort.Float64sAreSorted(slice) {
-        sort.Float64s(slice)
+    slice := res.sortedTook()
+    if len(slice) == 0 {
+        return
     }
     res.TookMax = slice[len(slice)-1]
 }
 
 func (res *Results) avg() {
-    slice := res.copyTook()
+    if res.Discard {
+        if res.Latency != nil {
+            res.TookAvg = res.Latency.Mean()
+        }
+        return
+    }
+
+    if len(res.Took) == 0 {
+        return
+    }
 
     var total float64
-    for _, n := range slice {
+    for _, n := range res.Took {
         total += n
     }
-    res.TookAvg = total / float64(len(slice))
+    res.TookAvg = total / float64(len(res.Took))
 }
 
 func (res *Results) med() {
-    slice := res.copyTook()
-    if !sort.Float64sAreSorted(slice) {
-        sort.Float64s(slice)
+    if res.Discard {
+        res.TookMed = res.Percentile(50)
+        return
+    }
+
+    res.TookMed = median(res.sortedTook())
+}
+
+func (res *Results) addTLS(result Result) {
+    if res.TLSVersions == nil {
+        res.TLSVersions = make(map[string]int)
+        res.TLSCiphers = make(map[string]int)
+    }
+
+    res.TLSVersions[result.TLSVersion]++
+    res.TLSCiphers[result.TLSCipher]++
+    if result.TLSResumed {
+        res.TLSResumed++
+    }
+}
+
+func (res *Results) addResponse(response float64) {
+    if !res.Discard {
+        res.ResponseTook = append(res.ResponseTook, response)
+    }
+
+    if res.ResponseLatency == nil {
+        res.ResponseLatency, _ = NewRecorder(res.Latency.Precision())
+    }
+    res.ResponseLatency.Record(response)
+}
+
+func (res *Results) countCode(code int) {
+    if code < 100 { // ignore
+    } else if code < 200 {
+        res.Code1xx++
+    } else if code < 300 {
+        res.Code2xx++
+    } else if code < 400 {
+        res.Code3xx++
+    } else if code < 500 {
+        res.Code4xx++
+    } else if code < 600 {
+        res.Code5xx++
+    }
+}
+
+// addError keeps err, or only counts it when discarding samples.
+func (res *Results) addError(err error) {
+    if res.Discard {
+        res.ErrorsTotal++
+        res.countError(err)
+        return
+    }
+    res.Errors = append(res.Errors, err)
+}
+
+func (res *Results) addTarget(result Result) {
+    if res.Targets == nil {
+        res.Targets = make(map[string]*Results)
+    }
+
+    target, ok := res.Targets[result.Target]
+    if !ok {
+        target = &Results{
+            Histogram: NewHistogram(res.Histogram.Bounds),
+            Discard:   res.Discard,
+        }
+        target.Latency, _ = NewRecorder(res.Latency.Precision())
+        res.Targets[result.Target] = target
+    }
+
+    // Target samples are appended, so canceled requests need no slot.
+    result.Index = len(target.Took)
+    if canceled(result.Error) {
+        result.Index = -1
+    }
+
+    result.Target = ""
+    target.Add(result)
+}
+
+// compact drops the slots of canceled requests from Took, Code and
+// Timings, left empty when later requests completed.
+func (res *Results) compact() {
+    if len(res.canceled) == 0 {
+        return
+    }
+
+    skip := make(map[int]bool, len(res.canceled))
+    for _, i := range res.canceled {
+        skip[i] = true
     }
 
+    // Took and Code may be sized up front, beyond Timings.
+    res.grow(len(res.Took))
+
+    n := 0
+    for i := range res.Took {
+        if skip[i] {
+            continue
+        }
+
+        res.Took[n], res.Code[n], res.Timings[n] = res.Took[i], res.Code[i], res.Timings[i]
+        n++
+    }
+
+    res.Took, res.Code, res.Timings = res.Took[:n], res.Code[:n], res.Timings[:n]
+    res.canceled = nil
+}
+
+// canceled reports whether err is from a request aborted by its run's
+// cancellation.
+func canceled(err error) bool {
+    return errors.Is(err, ErrCanceled) || errors.Is(err, context.Canceled)
+}
+
+// countError classifies err. Timeouts in the dial phase or the TLS
+// handshake count as connection timeouts, others (response header or
+// overall) as client timeouts.
+func (res *Results) countError(err error) {
+    var nerr net.Error
+    var operr *net.OpError
+
+    e := err.Error()
+    if errors.Is(err, ErrCheckFailed) {
+        res.ErrorsCheckFailed++
+    } else if canceled(err) {
+        res.ErrorsCanceled++
+    } else if errors.As(err, &nerr) && nerr.Timeout() {
+        if errors.As(err, &operr) && operr.Op == "dial" || strings.Contains(e, "TLS handshake timeout") {
+            res.ErrorsConnTimeout++
+        } else {
+            res.ErrorsClientTimeout++
+        }
+    } else if strings.Contains(e, "connection refused") {
+        res.ErrorsConnRefused++
+    } else if strings.Contains(e, "connection reset") {
+        res.ErrorsConnReset++
+    } else if strings.Contains(e, "connection timed out") {
+        res.ErrorsConnTimeout++
+    } else if strings.Contains(e, "no free file descriptors") {
+        res.ErrorsFdUnavail++
+    } else if strings.Contains(e, "no such host") {
+        res.ErrorsAddrUnavail++
+    } else {
+        res.ErrorsOther++
+    }
+}
+
+func (res *Results) phases() {
+    var dns, connect, tls, ttfb, transfer []float64
+    for _, t := range res.Timings {
+        dns = appendPositive(dns, t.DNS)
+        connect = appendPositive(connect, t.Connect)
+        tls = appendPositive(tls, t.TLS)
+        ttfb = appendPositive(ttfb, t.TTFB)
+        transfer = appendPositive(transfer, t.Transfer)
+    }
+
+    pcts := res.percentiles()
+    res.DNS = Summarize(dns, pcts...)
+    res.Connect = Summarize(connect, pcts...)
+    res.TLS = Summarize(tls, pcts...)
+    res.TTFB = Summarize(ttfb, pcts...)
+    res.Transfer = Summarize(transfer, pcts...)
+}
+
+func (res *Results) grow(l int) {
+    if n := l - len(res.Took); n > 0 {
+        res.Took = append(res.Took, make([]float64, n)...)
+    }
+
+    if n := l - len(res.Code); n > 0 {
+        res.Code = append(res.Code, make([]int, n)...)
+    }
+
+    if n := l - len(res.Timings); n > 0 {
+        res.Timings = append(res.Timings, make([]Timing, n)...)
+    }
+}
+
+func (res *Results) percentiles() []float64 {
+    if len(res.Percentiles) == 0 {
+        return DefaultPercentiles
+    }
+    return res.Percentiles
+}
+
+// sortedTook returns Took sorted, sorting a copy once per change.
+func (res *Results) sortedTook() []float64 {
+    if len(res.sorted) != len(res.Took) {
+        res.sorted = make([]float64, len(res.Took))
+        copy(res.sorted, res.Took)
+        sort.Float64s(res.sorted)
+    }
+    return res.sorted
+}
+
+func (s *Sizes) add(size int64) {
+    if s.Count == 0 || size < s.Min {
+        s.Min = size
+    }
+
+    if size > s.Max {
+        s.Max = size
+    }
+
+    s.Count++
+}
+
+func (s *Sizes) finalize(total int64) {
+    s.Avg = 0
+    if s.Count > 0 {
+        s.Avg = float64(total) / float64(s.Count)
+    }
+}
+
+/**
+ * Helpers
+ ******************************************/
+
+// median expects a sorted slice.
+func median(slice []float64) float64 {
     l := len(slice)
     switch l {
     case 0:
-        res.TookMed = float64(0)
+        return float64(0)
     case 1:
-        res.TookMed = slice[0]
+        return slice[0]
     case 2:
-        res.TookMed = slice[1]
-    default:
-        if math.Mod(float64(l), 2) == 0 {
-            index := int(math.Floor(float64(l)/2) - 1)
-            lower := slice[index]
-            upper := slice[index+1]
-            res.TookMed = (lower + upper) / 2
-        } else {
-            res.TookMed = slice[l/2]
-        }
+        return slice[1]
+    }
+
+    if math.Mod(float64(l), 2) == 0 {
+        index := int(math.Floor(float64(l)/2) - 1)
+        return (slice[index] + slice[index+1]) / 2
     }
+    return slice[l/2]
 }
 
-func (res *Results) pct() {
-    res.Took85th = res.CalculatePct(85)
-    res.Took90th = res.CalculatePct(90)
-    res.Took95th = res.CalculatePct(95)
-    res.Took99th = res.CalculatePct(99)
+// percentile expects a sorted slice.
+func percentile(slice []float64, pct float64) float64 {
+    l := len(slice)
+    if math.IsNaN(pct) {
+        return float64(0)
+    }
+
+    switch l {
+    case 0:
+        return float64(0)
+    case 1:
+        return slice[0]
+    case 2:
+        return slice[1]
+    }
+
+    // Out of range percentiles are clamped, as by Recorder.ValueAt.
+    index := int(math.Floor(((float64(l)/100)*pct)+0.5) - 1)
+    if index < 0 {
+        index = 0
+    }
+
+    if index > l-1 {
+        index = l - 1
+    }
+    return slice[index]
 }
 
-func (res *Results) copyTook() []float64 {
-    slice := make([]float64, len(res.Took))
-    copy(slice, res.Took)
+func appendPositive(slice []float64, n float64) []float64 {
+    if n > 0 {
+        return append(slice, n)
+    }
     return slice
 }
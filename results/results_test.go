@@ -1,8 +1,13 @@
 package results
 
 import (
+    "errors"
     "fmt"
+    "math"
+    "net"
+    "net/url"
     "testing"
+    "time"
     . "github.com/jmervine/GoT"
 )
 
@@ -41,6 +46,119 @@ func TestAdd(T *testing.T) {
     Go(T).AssertEqual(r.Code[9], 500, "")
 }
 
+func TestAddGrows(T *testing.T) {
+    r := Results{}
+    r.Add(newRT(3, 300.0, 200))
+    Go(T).AssertLength(r.Took, 4)
+    Go(T).AssertLength(r.Code, 4)
+    Go(T).AssertEqual(r.Took[3], 300.0, "")
+
+    r.Add(newRT(1, 100.0, 200))
+    Go(T).AssertLength(r.Took, 4)
+    Go(T).AssertEqual(r.Took[1], 100.0, "")
+}
+
+func TestFinalizeEmpty(T *testing.T) {
+    r := Results{}
+    r.Finalize()
+    Go(T).AssertEqual(r.Replies, 0, "")
+    Go(T).AssertEqual(r.TookMin, 0.0, "")
+    Go(T).AssertEqual(r.TookAvg, 0.0, "")
+}
+
+func TestFinalizeErrors(T *testing.T) {
+    r := newRS(3)
+    r.Add(Result{Index: 0, Error: errors.New("dial tcp: connection refused")})
+    r.Add(Result{Index: 1, Error: errors.New("unexpected EOF")})
+    r.Add(Result{Index: 2, Code: 200})
+    r.Add(Result{Index: 3, Code: 200, Error: fmt.Errorf("%w: unexpected status", ErrCheckFailed)})
+    r.Finalize()
+
+    Go(T).AssertEqual(r.ErrorsTotal, 3, "")
+    Go(T).AssertEqual(r.ErrorsConnRefused, 1, "")
+    Go(T).AssertEqual(r.ErrorsCheckFailed, 1, "")
+    Go(T).AssertEqual(r.ErrorsOther, 1, "")
+}
+
+func TestFinalizeCanceled(T *testing.T) {
+    canceled := fmt.Errorf("%w: context canceled", ErrCanceled)
+
+    r := Results{}
+    r.Add(Result{Index: 1, Took: 99.0, Error: canceled, Target: "GET /a"})
+    r.Add(Result{Index: 0, Took: 10.0, Code: 200, Target: "GET /a"})
+    r.Add(Result{Index: 2, Took: 20.0, Code: 200, Target: "GET /a"})
+    r.Add(Result{Index: 3, Took: 99.0, Error: canceled, Intended: time.Now()})
+    r.Finalize()
+
+    // Canceled requests are errors, but not replies or latency samples.
+    Go(T).AssertEqual(r.ErrorsCanceled, 2, "")
+    Go(T).AssertEqual(r.Replies, 2, "")
+    Go(T).AssertEqual(r.Took, []float64{10.0, 20.0}, "")
+    Go(T).AssertEqual(r.Code, []int{200, 200}, "")
+    Go(T).AssertEqual(r.TookMax, 20.0, "")
+    Go(T).AssertEqual(r.Latency.Count(), int64(2), "")
+    Go(T).AssertEqual(r.Histogram.Total, int64(2), "")
+    Go(T).AssertEqual(r.Response.Count, 0, "")
+
+    Go(T).AssertEqual(r.Targets["GET /a"].Replies, 2, "")
+    Go(T).AssertEqual(r.Targets["GET /a"].Requested, 3, "")
+    Go(T).AssertEqual(r.Targets["GET /a"].ErrorsCanceled, 1, "")
+
+    // Presized samples, as by Connector.New.
+    r = newRS(3)
+    r.Add(Result{Index: 0, Took: 99.0, Error: canceled})
+    r.Add(Result{Index: 1, Took: 10.0, Code: 200})
+    r.Finalize()
+
+    Go(T).AssertEqual(r.Took, []float64{10.0, 0.0}, "")
+    Go(T).AssertEqual(r.ErrorsCanceled, 1, "")
+}
+
+func TestFinalizeTimeouts(T *testing.T) {
+    dial := &url.Error{Op: "Get", URL: "http://localhost",
+        Err: &net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}}}
+    read := &url.Error{Op: "Get", URL: "http://localhost",
+        Err: &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}}
+    client := &url.Error{Op: "Get", URL: "http://localhost", Err: timeoutError{}}
+    handshake := &url.Error{Op: "Get", URL: "https://localhost", Err: handshakeTimeoutError{}}
+
+    r := newRS(3)
+    r.Add(Result{Index: 0, Error: dial})
+    r.Add(Result{Index: 1, Error: read})
+    r.Add(Result{Index: 2, Error: client})
+    r.Add(Result{Index: 3, Error: handshake})
+    r.Finalize()
+
+    Go(T).AssertEqual(r.ErrorsConnTimeout, 2, "")
+    Go(T).AssertEqual(r.ErrorsClientTimeout, 2, "")
+    Go(T).AssertEqual(r.ErrorsOther, 0, "")
+
+    // Finalizing again doesn't double count.
+    r.Finalize()
+    Go(T).AssertEqual(r.ErrorsClientTimeout, 2, "")
+}
+
+func TestAddSession(T *testing.T) {
+    r := Results{}
+    r.AddSession(SessionResult{Took: 30, Requests: 3, Completed: true})
+    r.AddSession(SessionResult{Took: 10, Requests: 1, Completed: true})
+    r.AddSession(SessionResult{Took: 5, Requests: 1, Failed: true})
+    r.AddSession(SessionResult{Took: 1})
+    r.Finalize()
+
+    Go(T).AssertEqual(r.Sessions, 4)
+    Go(T).AssertEqual(r.SessionsCompleted, 2)
+    Go(T).AssertEqual(r.SessionsFailed, 1)
+    Go(T).AssertEqual(r.Session.Count, 2)
+    Go(T).AssertEqual(r.Session.Min, 10.0)
+    Go(T).AssertEqual(r.Session.Max, 30.0)
+
+    report := r.Report(false)
+    Go(T).AssertEqual(report.Sessions.Completed, 2)
+    empty := populatedRS(1)
+    Go(T).Assert(empty.Report(false).Sessions == nil)
+}
+
 func TestMin(T *testing.T) {
     r := populatedRS(5)
 
@@ -76,14 +194,214 @@ func TestCalculatePct(T *testing.T) {
     Go(T).AssertEqual(r.CalculatePct(75), 800.0, "")
 }
 
-func TestPct(T *testing.T) {
+func TestCalculatePctUnsorted(T *testing.T) {
+    r := newRS(4)
+    for i, took := range []float64{400, 100, 300, 200} {
+        r.Add(newRT(i, took, 200))
+    }
+
+    Go(T).AssertEqual(r.CalculatePct(50), 200.0, "")
+    Go(T).AssertEqual(r.CalculatePct(99), 400.0, "")
+}
+
+func TestSummarize(T *testing.T) {
+    s := Summarize([]float64{300, 100, 200})
+    Go(T).AssertEqual(s.Count, 3, "")
+    Go(T).AssertEqual(s.Min, 100.0, "")
+    Go(T).AssertEqual(s.Avg, 200.0, "")
+    Go(T).AssertEqual(s.Max, 300.0, "")
+    Go(T).AssertEqual(s.Med, 200.0, "")
+    Go(T).AssertEqual(s.Percentile(99), 300.0, "")
+    Go(T).AssertLength(s.Percentiles, len(DefaultPercentiles))
+
+    s = Summarize([]float64{300, 100, 200}, 50, 99.9)
+    Go(T).AssertLength(s.Percentiles, 2)
+    Go(T).AssertEqual(s.Percentile(50), 200.0, "")
+    Go(T).AssertEqual(s.Percentile(85), 0.0, "")
+
+    Go(T).AssertEqual(Summarize(nil).Count, 0, "")
+}
+
+func TestPhases(T *testing.T) {
+    r := newRS(3)
+    r.Add(Result{Index: 0, Took: 10, Timing: Timing{DNS: 1, Connect: 2, TTFB: 5, Transfer: 2}})
+    r.Add(Result{Index: 1, Took: 6, Timing: Timing{TTFB: 4, Transfer: 2}})
+    r.Add(Result{Index: 2, Took: 8, Timing: Timing{Connect: 4, TTFB: 3, Transfer: 1}})
+    r.Finalize()
+
+    Go(T).AssertEqual(r.DNS.Count, 1, "")
+    Go(T).AssertEqual(r.Connect.Count, 2, "")
+    Go(T).AssertEqual(r.Connect.Avg, 3.0, "")
+    Go(T).AssertEqual(r.TLS.Count, 0, "")
+    Go(T).AssertEqual(r.TTFB.Max, 5.0, "")
+    Go(T).AssertEqual(r.Transfer.Min, 1.0, "")
+}
+
+func TestPercentile(T *testing.T) {
     r := populatedRS(20)
 
-    r.pct()
-    Go(T).AssertEqual(r.Took99th, 1050.0, "")
-    Go(T).AssertEqual(r.Took95th, 1000.0, "")
-    Go(T).AssertEqual(r.Took90th, 950.0, "")
-    Go(T).AssertEqual(r.Took85th, 900.0, "")
+    Go(T).AssertEqual(r.Percentile(99), 1050.0, "")
+    Go(T).AssertEqual(r.Percentile(95), 1000.0, "")
+    Go(T).AssertEqual(r.Percentile(90), 950.0, "")
+    Go(T).AssertEqual(r.Percentile(85), 900.0, "")
+    Go(T).AssertEqual(r.Percentile(99.9), 1050.0, "")
+
+    // Out of range percentiles are clamped, rather than panicking.
+    Go(T).AssertEqual(r.Percentile(150), 1050.0, "")
+    Go(T).AssertEqual(r.Percentile(-5), 100.0, "")
+    Go(T).AssertEqual(r.Percentile(math.NaN()), 0.0, "")
+}
+
+func TestDiscard(T *testing.T) {
+    r := Results{Discard: true}
+    for i := 0; i < 1000; i++ {
+        r.Add(Result{Index: i, Took: float64(i + 1), Code: 200})
+    }
+    r.Add(Result{Index: 1000, Took: 5000, Code: 500, Target: "GET /slow"})
+    r.TotalTime = 2
+    r.Finalize()
+
+    Go(T).AssertLength(r.Took, 0)
+    Go(T).AssertLength(r.Code, 0)
+    Go(T).AssertEqual(r.Replies, 1001, "")
+    Go(T).AssertEqual(r.Code2xx, 1000, "")
+    Go(T).AssertEqual(r.Code5xx, 1, "")
+    Go(T).AssertEqual(r.TookMin, 1.0, "")
+    Go(T).AssertEqual(r.TookMax, 5000.0, "")
+    Go(T).Assert(math.Abs(r.TookMed-501) < 0.5)
+    Go(T).Assert(math.Abs(r.Percentile(99)-991) < 1)
+    Go(T).AssertEqual(r.Percentile(100), 5000.0, "")
+    Go(T).AssertEqual(r.Targets["GET /slow"].Replies, 1, "")
+    Go(T).AssertEqual(r.Targets["GET /slow"].Requested, 1, "")
+    Go(T).AssertEqual(r.Targets["GET /slow"].ReqPerSec, 0.5, "")
+}
+
+func TestDiscardErrors(T *testing.T) {
+    r := Results{Discard: true}
+    r.Add(Result{Index: 0, Took: 10, Error: errors.New("dial tcp: connection refused")})
+    r.Add(Result{Index: 1, Error: fmt.Errorf("%w: context canceled", ErrCanceled)})
+    r.Finalize()
+
+    // Errors are counted, not kept.
+    Go(T).AssertLength(r.Errors, 0)
+    Go(T).AssertEqual(r.ErrorsTotal, 2, "")
+    Go(T).AssertEqual(r.ErrorsConnRefused, 1, "")
+    Go(T).AssertEqual(r.ErrorsCanceled, 1, "")
+
+    // Finalizing again doesn't double count.
+    r.Finalize()
+    Go(T).AssertEqual(r.ErrorsTotal, 2, "")
+}
+
+func TestResponse(T *testing.T) {
+    start := time.Now()
+    late := Result{Took: 10, Start: start.Add(50 * time.Millisecond), Intended: start}
+    Go(T).AssertEqual(late.Response(), 60.0, "")
+
+    early := Result{Took: 10, Start: start, Intended: start.Add(time.Second)}
+    Go(T).AssertEqual(early.Response(), 10.0, "")
+    Go(T).AssertEqual(Result{Took: 10, Start: start}.Response(), 10.0, "")
+
+    r := Results{}
+    r.Add(Result{Index: 0, Took: 10, Code: 200, Start: start, Intended: start})
+    late.Index = 1
+    r.Add(late)
+    r.Add(Result{Index: 2, Took: 20, Code: 200, Start: start})
+    r.Finalize()
+
+    Go(T).AssertEqual(r.TookMax, 20.0, "")
+    Go(T).AssertEqual(r.Response.Count, 2, "")
+    Go(T).AssertEqual(r.Response.Min, 10.0, "")
+    Go(T).AssertEqual(r.Response.Max, 60.0, "")
+    Go(T).AssertEqual(r.Response.Percentile(99), 60.0, "")
+
+    // Discarding, Response comes from ResponseLatency.
+    d := Results{Discard: true}
+    d.Add(late)
+    d.Finalize()
+
+    Go(T).AssertLength(d.ResponseTook, 0)
+    Go(T).AssertEqual(d.Response.Count, 1, "")
+    Go(T).Assert(math.Abs(d.Response.Max-60) < 0.1)
+}
+
+func TestSizes(T *testing.T) {
+    r := Results{TotalTime: 2}
+    r.Add(Result{Index: 0, Code: 200, HeaderLength: 100, ContentLength: 924, TotalLength: 1024})
+    r.Add(Result{Index: 1, Code: 200, HeaderLength: 100, ContentLength: 2972, TotalLength: 3072})
+    r.Add(Result{Index: 2, Error: errors.New("connection refused")})
+    r.Finalize()
+
+    Go(T).AssertEqual(r.TotalLength, int64(4096), "")
+    Go(T).AssertEqual(r.HeaderLength, int64(200), "")
+    Go(T).AssertEqual(r.TotalSize.Count, 2, "")
+    Go(T).AssertEqual(r.TotalSize.Min, int64(1024), "")
+    Go(T).AssertEqual(r.TotalSize.Max, int64(3072), "")
+    Go(T).AssertEqual(r.TotalSize.Avg, 2048.0, "")
+    Go(T).AssertEqual(r.ContentSize.Avg, 1948.0, "")
+    Go(T).AssertEqual(r.KBPerSec, 2.0, "")
+}
+
+func TestTLS(T *testing.T) {
+    r := Results{}
+    r.Add(Result{Index: 0, Code: 200, TLSVersion: "TLS 1.3", TLSCipher: "TLS_AES_128_GCM_SHA256"})
+    r.Add(Result{Index: 1, Code: 200, TLSVersion: "TLS 1.3", TLSCipher: "TLS_AES_128_GCM_SHA256", TLSResumed: true})
+    r.Add(Result{Index: 2, Code: 200})
+    r.Finalize()
+
+    Go(T).AssertEqual(r.TLSVersions["TLS 1.3"], 2, "")
+    Go(T).AssertEqual(r.TLSCiphers["TLS_AES_128_GCM_SHA256"], 2, "")
+    Go(T).AssertEqual(r.TLSResumed, 1, "")
+    Go(T).AssertEqual(r.Report(false).TLS.Resumed, 1, "")
+
+    plain := populatedRS(5)
+    plain.Finalize()
+    Go(T).Assert(plain.Report(false).TLS == nil)
+}
+
+func TestStreams(T *testing.T) {
+    r := Results{}
+    for i, c := range []int{1, 1, 2, 1, 2, 1} {
+        r.Add(Result{Index: i, Code: 200, Proto: "HTTP/2.0", Conn: c})
+    }
+    r.Add(Result{Index: 6, Code: 200, Proto: "HTTP/1.1"})
+    r.Finalize()
+
+    Go(T).AssertEqual(r.Protocols["HTTP/2.0"], 6, "")
+    Go(T).AssertEqual(r.Protocols["HTTP/1.1"], 1, "")
+    Go(T).AssertEqual(r.StreamsPerConn.Count, 2, "")
+    Go(T).AssertEqual(r.StreamsPerConn.Min, int64(2), "")
+    Go(T).AssertEqual(r.StreamsPerConn.Max, int64(4), "")
+    Go(T).AssertEqual(r.StreamsPerConn.Avg, 3.0, "")
+    Go(T).AssertEqual(r.Report(false).Streams.Count, 2, "")
+}
+
+func TestReset(T *testing.T) {
+    r := populatedRS(5)
+    r.Percentiles = []float64{99.9}
+    r.Connections = 5
+    r.Add(Result{Index: 5, Code: 200, ContentLength: 10, Target: "GET /a"})
+    r.Finalize()
+    r.Reset()
+
+    Go(T).AssertLength(r.Took, 0)
+    Go(T).AssertEqual(cap(r.Took), cap(r.Code))
+    Go(T).AssertEqual(r.Connections, 0, "")
+    Go(T).AssertEqual(r.Replies, 0, "")
+    Go(T).AssertEqual(r.ContentLength, int64(0), "")
+    Go(T).AssertLength(r.Targets, 0)
+    Go(T).AssertEqual(r.Percentiles, []float64{99.9}, "")
+
+    // Recorders are emptied, keeping their settings.
+    r.Histogram = NewHistogram([]float64{10, 20})
+    r.Latency, _ = NewRecorder(2)
+    r.Add(Result{Index: 0, Took: 15, Code: 200})
+    r.Reset()
+
+    Go(T).AssertEqual(r.Histogram.Total, int64(0), "")
+    Go(T).AssertEqual(r.Histogram.Bounds, []float64{10, 20}, "")
+    Go(T).AssertEqual(r.Latency.Count(), int64(0), "")
+    Go(T).AssertEqual(r.Latency.Precision(), 2, "")
 }
 
 func TestFinalize(T *testing.T) {
@@ -94,7 +412,7 @@ func TestFinalize(T *testing.T) {
     Go(T).AssertEqual(r.TookAvg, 200.0, "")
     Go(T).AssertEqual(r.TookMed, 200.0, "")
     Go(T).AssertEqual(r.TookMax, 300.0, "")
-    Go(T).AssertEqual(r.Took99th, 300.0, "")
+    Go(T).AssertEqual(r.Percentile(99), 300.0, "")
 }
 
 /***
@@ -136,6 +454,19 @@ func ExampleResults() {
  * Helpers
  ******************************/
 
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// handshakeTimeoutError is as net/http's TLS handshake timeout.
+type handshakeTimeoutError struct{}
+
+func (handshakeTimeoutError) Error() string   { return "net/http: TLS handshake timeout" }
+func (handshakeTimeoutError) Timeout() bool   { return true }
+func (handshakeTimeoutError) Temporary() bool { return true }
+
 func newRS(l int) Results {
     return Results{
         Took: make([]float64, l),
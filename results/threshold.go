@@ -0,0 +1,200 @@
+package results
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "os"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// Threshold is a pass/fail rule on finalized Results, e.g. "p99<250ms",
+// "errors<1%" or "rps>500", see ParseThreshold.
+type Threshold struct {
+    Metric  string
+    Op      string
+    Value   float64
+    Percent bool
+
+    rule string
+}
+
+// Violation is a Threshold breached by Results, with the Actual value.
+type Violation struct {
+    Threshold Threshold
+    Actual    float64
+}
+
+// ParseThreshold parses a rule of the form METRIC OP VALUE[UNIT], where
+// OP is one of <, <=, > or >=. Metrics are:
+//
+//	min, avg, med, max, pN   request time, in ms, or s with an s unit
+//	rps, cps                 request and connection rate, per second
+//	errors                   error count, or % of requests with a % unit
+func ParseThreshold(rule string) (Threshold, error) {
+    t := Threshold{rule: strings.TrimSpace(rule)}
+
+    m := thresholdRule.FindStringSubmatch(t.rule)
+    if m == nil {
+        return t, fmt.Errorf("invalid threshold %q, expected e.g. p99<250ms", rule)
+    }
+
+    t.Metric, t.Op = m[1], m[2]
+    t.Value, _ = strconv.ParseFloat(m[3], 64)
+    unit := m[4]
+
+    switch {
+    case isLatency(t.Metric):
+        if unit == "s" {
+            t.Value *= 1000
+        } else if unit != "" && unit != "ms" {
+            return t, fmt.Errorf("invalid threshold %q, %s expects ms or s", rule, t.Metric)
+        }
+    case t.Metric == "rps" || t.Metric == "cps":
+        if unit != "" {
+            return t, fmt.Errorf("invalid threshold %q, %s expects no unit", rule, t.Metric)
+        }
+    case t.Metric == "errors":
+        if unit != "" && unit != "%" {
+            return t, fmt.Errorf("invalid threshold %q, errors expects a count or %%", rule)
+        }
+        t.Percent = unit == "%"
+    default:
+        return t, fmt.Errorf("invalid threshold %q, unknown metric %q", rule, t.Metric)
+    }
+
+    return t, nil
+}
+
+// ReadThresholds parses thresholds, one per line. Blank lines and lines
+// starting with # are ignored.
+func ReadThresholds(r io.Reader) ([]Threshold, error) {
+    var thresholds []Threshold
+
+    scanner := bufio.NewScanner(r)
+    for n := 1; scanner.Scan(); n++ {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+
+        t, err := ParseThreshold(line)
+        if err != nil {
+            return nil, fmt.Errorf("line %d: %v", n, err)
+        }
+        thresholds = append(thresholds, t)
+    }
+
+    return thresholds, scanner.Err()
+}
+
+// LoadThresholds reads a threshold file, see ReadThresholds.
+func LoadThresholds(path string) ([]Threshold, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    return ReadThresholds(file)
+}
+
+// String returns the rule as given, e.g. "p99<250ms".
+func (t Threshold) String() string {
+    return t.rule
+}
+
+// Value returns the Threshold's metric from finalized Results, in the
+// Threshold's units.
+func (res *Results) Value(t Threshold) float64 {
+    switch t.Metric {
+    case "min":
+        return res.TookMin
+    case "avg":
+        return res.TookAvg
+    case "med":
+        return res.TookMed
+    case "max":
+        return res.TookMax
+    case "rps":
+        return res.ReqPerSec
+    case "cps":
+        return res.ConnPerSec
+    case "errors":
+        if !t.Percent {
+            return float64(res.ErrorsTotal)
+        }
+
+        if res.Requested == 0 {
+            return 0
+        }
+        return float64(res.ErrorsTotal) / float64(res.Requested) * 100
+    }
+
+    // pN, validated by ParseThreshold.
+    pct, _ := strconv.ParseFloat(t.Metric[1:], 64)
+    return res.Percentile(pct)
+}
+
+// Evaluate checks finalized Results against thresholds, returning those
+// breached.
+func (res *Results) Evaluate(thresholds []Threshold) []Violation {
+    var violations []Violation
+
+    for _, t := range thresholds {
+        actual := res.Value(t)
+        if !t.pass(actual) {
+            violations = append(violations, Violation{Threshold: t, Actual: actual})
+        }
+    }
+
+    return violations
+}
+
+// String describes the Violation, e.g. "p99<250ms (actual 312.40ms)".
+func (v Violation) String() string {
+    unit := ""
+    switch {
+    case v.Threshold.Percent:
+        unit = "%"
+    case isLatency(v.Threshold.Metric):
+        unit = "ms"
+    }
+
+    return fmt.Sprintf("%s (actual %.2f%s)", v.Threshold, v.Actual, unit)
+}
+
+/**
+ * Private Methods
+ ******************************************/
+
+var thresholdRule = regexp.MustCompile(`^([a-z]+[0-9.]*)\s*(<=|>=|<|>)\s*([0-9]+(?:\.[0-9]+)?)\s*(ms|s|%)?$`)
+var percentileMetric = regexp.MustCompile(`^p[0-9]+(\.[0-9]+)?$`)
+
+func (t Threshold) pass(actual float64) bool {
+    switch t.Op {
+    case "<":
+        return actual < t.Value
+    case "<=":
+        return actual <= t.Value
+    case ">":
+        return actual > t.Value
+    }
+    return actual >= t.Value
+}
+
+func isLatency(metric string) bool {
+    switch metric {
+    case "min", "avg", "med", "max":
+        return true
+    }
+
+    if !percentileMetric.MatchString(metric) {
+        return false
+    }
+
+    pct, _ := strconv.ParseFloat(metric[1:], 64)
+    return pct > 0 && pct <= 100
+}
@@ -0,0 +1,62 @@
+package results
+
+import (
+    "strings"
+    "testing"
+    . "github.com/jmervine/GoT"
+)
+
+func TestParseThreshold(T *testing.T) {
+    t, err := ParseThreshold("p99<250ms")
+    Go(T).Assert(err == nil)
+    Go(T).AssertEqual(t.Metric, "p99")
+    Go(T).AssertEqual(t.Op, "<")
+    Go(T).AssertEqual(t.Value, 250.0)
+    Go(T).AssertEqual(t.String(), "p99<250ms")
+
+    t, _ = ParseThreshold("avg <= 1.5s")
+    Go(T).AssertEqual(t.Value, 1500.0)
+
+    t, _ = ParseThreshold("errors<1%")
+    Go(T).Assert(t.Percent)
+
+    for _, rule := range []string{
+        "", "p99", "p99=250ms", "p99<fast", "p0<1", "p101<1", "rps>5ms",
+        "errors<1s", "bogus<1", "avg<1%",
+    } {
+        _, err := ParseThreshold(rule)
+        Go(T).Refute(err == nil, rule)
+    }
+}
+
+func TestEvaluate(T *testing.T) {
+    r := populatedRS(10)
+    r.Requested = 10
+    r.ReqPerSec = 400
+    r.Errors = []error{ErrCanceled}
+    r.Finalize()
+
+    var thresholds []Threshold
+    for _, rule := range []string{"max<=550ms", "p99<250ms", "errors<=10%", "errors<1", "rps>500"} {
+        t, _ := ParseThreshold(rule)
+        thresholds = append(thresholds, t)
+    }
+
+    violations := r.Evaluate(thresholds)
+    Go(T).AssertLength(violations, 3)
+    Go(T).AssertEqual(violations[0].String(), "p99<250ms (actual 550.00ms)")
+    Go(T).AssertEqual(violations[1].String(), "errors<1 (actual 1.00)")
+    Go(T).AssertEqual(violations[2].Actual, 400.0)
+}
+
+func TestReadThresholds(T *testing.T) {
+    thresholds, err := ReadThresholds(strings.NewReader("# SLOs\np95 < 200ms\n\nrps>=100\n"))
+    Go(T).Assert(err == nil)
+    Go(T).AssertLength(thresholds, 2)
+
+    _, err = ReadThresholds(strings.NewReader("p95<200ms\nnope\n"))
+    Go(T).Refute(err == nil)
+
+    _, err = LoadThresholds("/does/not/exist")
+    Go(T).Refute(err == nil)
+}